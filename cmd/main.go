@@ -16,9 +16,16 @@ import(
 	"github.com/lambda-go-oauth2/internal/domain/model"
 	"github.com/lambda-go-oauth2/internal/infrastructure/config"
 	"github.com/lambda-go-oauth2/internal/infrastructure/server"	
+	"github.com/lambda-go-oauth2/internal/infrastructure/jwks"
+	"github.com/lambda-go-oauth2/internal/infrastructure/source"
+	"github.com/lambda-go-oauth2/internal/infrastructure/repository"
+	"github.com/lambda-go-oauth2/internal/infrastructure/secret"
+	"github.com/lambda-go-oauth2/internal/infrastructure/introspection"
+	"github.com/lambda-go-oauth2/internal/infrastructure/ocsp"
 
 	go_core_otel_trace 	 "github.com/eliezerraj/go-core/v2/otel/trace"
 	go_core_aws_s3 "github.com/eliezerraj/go-core/v2/aws/s3"
+	go_core_aws_dynamo "github.com/eliezerraj/go-core/v2/aws/dynamoDB"
 
 	// traces
 	"go.opentelemetry.io/otel"
@@ -28,7 +35,6 @@ import(
 	"github.com/aws/aws-lambda-go/lambda" //enable this line for run in AWS
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-lambda-go/otellambda" //enable this line for run in AWS
 	// ---------------------------  use it for a mock local ---------------------------
-	//"encoding/json"  
 	//"github.com/aws/aws-lambda-go/events" 
 	// ---------------------------  use it for a mock local ---------------------------	
 )
@@ -38,6 +44,14 @@ type AppContext struct {
 	Logger           zerolog.Logger
 	Server           *model.AppServer
 	TracerProvider   *go_core_otel_trace.TracerProvider
+	RevocationStore	 service.RevocationStore
+	CredentialScopeStore service.CredentialScopeStore
+	SecretProvider	 service.SecretProvider
+	KeySource		 service.KeySource
+	KeySources		 map[string]service.KeySource
+	Introspector	 service.Introspector
+	CrlSource		 service.CrlSource
+	OcspResponder	 service.OcspResponder
 }
 
 // Global logger for init and main entry point only
@@ -103,6 +117,16 @@ func setupAppContext(ctx context.Context) (*AppContext, error) {
 		Application:    allConfigs.Application,
 		AwsService:     allConfigs.AwsService,
 		EnvTrace:       allConfigs.OtelTrace,
+		TokenValidation: allConfigs.TokenValidation,
+		ClaimValidation: allConfigs.ClaimValidation,
+		Revocation:		allConfigs.Revocation,
+		Rbac:			allConfigs.Rbac,
+		HmacSecret:		allConfigs.HmacSecret,
+		Oidc:			allConfigs.Oidc,
+		Introspection:	allConfigs.Introspection,
+		TokenEncryption: allConfigs.TokenEncryption,
+		ClientCertValidation: allConfigs.ClientCertValidation,
+		SpiffeAuthorization: allConfigs.SpiffeAuthorization,
 	}
 
 	// Setup OTEL tracer if enabled
@@ -123,6 +147,8 @@ func setupAppContext(ctx context.Context) (*AppContext, error) {
 		return nil, fmt.Errorf("configuration s3: %w", err)
 	}
 
+	sourceLoader := source.NewSourceLoader(bucketS3, &logger)
+
 	// Load the private key
 	rsaKey := model.RsaKey{}
 	privateKey, err := bucketS3.GetObject( ctx, 
@@ -139,36 +165,235 @@ func setupAppContext(ctx context.Context) (*AppContext, error) {
 		return nil, fmt.Errorf("configuration parse priv keys to pem: %w", err)
 	}
 
-	// Load the private key
-	publicKey, err := bucketS3.GetObject(ctx, 
-										 appServer.AwsService.BucketNameRSAKey,
-										 appServer.AwsService.FilePathRSA,
-										 appServer.AwsService.FileNameRSAPubKey)
-	if err != nil{
-		return nil, fmt.Errorf("configuration get pub keys from s3: %w", err)
-	}
-
-	rsaPublic, err := certificate.ParsePemToRSAPub(publicKey,
-												   &logger)
-	if err != nil{
-		return nil, fmt.Errorf("configuration parse pub keys to pem: %w", err)
-	}
-
 	// Load everything in rsa key model
 	rsaKey.AuthenticationModel = appServer.Application.AuthenticationModel
 	rsaKey.Kid = appServer.AwsService.Kid
 
-	rsaKey.HsaKey 		= "SECRET-12345" // for simplicity 
-	rsaKey.RsaPublic 	= rsaPublic
 	rsaKey.RsaPrivate 	= rsaPrivate
 	rsaKey.RsaPrivatePem = string(*privateKey)
-	rsaKey.RsaPublicPem = string(*publicKey)
+
+	// Load the public key (when the oidc issuer is informed the keys are fetched from its jwks_uri)
+	if appServer.Oidc.IssuerUrl == "" {
+		publicKey, err := bucketS3.GetObject(ctx, 
+											 appServer.AwsService.BucketNameRSAKey,
+											 appServer.AwsService.FilePathRSA,
+											 appServer.AwsService.FileNameRSAPubKey)
+		if err != nil{
+			return nil, fmt.Errorf("configuration get pub keys from s3: %w", err)
+		}
+
+		rsaPublic, err := certificate.ParsePemToRSAPub(publicKey,
+													   &logger)
+		if err != nil{
+			return nil, fmt.Errorf("configuration parse pub keys to pem: %w", err)
+		}
+
+		rsaKey.RsaPublic 	= rsaPublic
+		rsaKey.RsaPublicPem = string(*publicKey)
+	}
+
+	// Load the ECDSA public key (optional)
+	if appServer.AwsService.FileNameECPubKey != "" {
+		ecPublicKey, err := bucketS3.GetObject(ctx, 
+											   appServer.AwsService.BucketNameRSAKey,
+											   appServer.AwsService.FilePathRSA,
+											   appServer.AwsService.FileNameECPubKey)
+		if err != nil{
+			return nil, fmt.Errorf("configuration get ec pub keys from s3: %w", err)
+		}
+
+		rsaKey.EcPublic, err = certificate.ParsePemToECDSAPub(ecPublicKey,
+															  &logger)
+		if err != nil{
+			return nil, fmt.Errorf("configuration parse ec pub keys to pem: %w", err)
+		}
+	}
+
+	// Load the Ed25519 public key (optional)
+	if appServer.AwsService.FileNameEdPubKey != "" {
+		edPublicKey, err := bucketS3.GetObject(ctx, 
+											   appServer.AwsService.BucketNameRSAKey,
+											   appServer.AwsService.FilePathRSA,
+											   appServer.AwsService.FileNameEdPubKey)
+		if err != nil{
+			return nil, fmt.Errorf("configuration get ed25519 pub keys from s3: %w", err)
+		}
+
+		rsaKey.EdPublic, err = certificate.ParsePemToEd25519Pub(edPublicKey,
+																&logger)
+		if err != nil{
+			return nil, fmt.Errorf("configuration parse ed25519 pub keys to pem: %w", err)
+		}
+	}
+
+	// Load the jwks (kid rotation)
+	if appServer.AwsService.JwksSource != "" {
+		jwksLoader := jwks.NewJwksLoader(sourceLoader, &logger)
+		rsaKey.Jwks, err = jwksLoader.Load(ctx, appServer.AwsService.JwksSource)
+		if err != nil{
+			return nil, fmt.Errorf("configuration load jwks: %w", err)
+		}
+	}
+	// Load the ca cert (trust bundle of the mTLS client certificates)
+	if appServer.AwsService.FileNameCaCertKey != "" {
+		caCert, err := bucketS3.GetObject(ctx, 
+										  appServer.AwsService.BucketNameRSAKey,
+										  appServer.AwsService.FilePathRSA,
+										  appServer.AwsService.FileNameCaCertKey)
+		if err != nil{
+			return nil, fmt.Errorf("configuration get ca cert from s3: %w", err)
+		}
+		rsaKey.CaCert = *caCert
+	}
 	appServer.RsaKey 	= &rsaKey	
 
+	// Load the crl source (reloaded on a schedule)
+	// The crl check is opt-in, it requires the ca cert that signs the crl
+	var crlSource service.CrlSource
+	if appServer.AwsService.FileNameCrlKey != "" {
+		if rsaKey.CaCert == "" {
+			logger.Warn().
+				Str("crl_file_key", appServer.AwsService.FileNameCrlKey).
+				Msg("crl check DISABLED, CA_CERT_FILE_KEY not informed")
+		} else {
+			crlSource = source.NewS3ObjectSource(bucketS3,
+												 appServer.AwsService.BucketNameRSAKey,
+												 appServer.AwsService.FilePathRSA,
+												 appServer.AwsService.FileNameCrlKey)
+		}
+	}
+
+	// Load the dynamo database (revocation and user scopes)
+	var database *go_core_aws_dynamo.DatabaseDynamoDB
+	if appServer.Revocation.Enabled || appServer.Rbac.Enabled {
+		database, err = go_core_aws_dynamo.NewDatabaseDynamo(&awsCfg,
+															 &logger)
+		if err != nil {
+			return nil, fmt.Errorf("configuration dynamo: %w", err)
+		}
+	}
+
+	// Load the revocation store (jwt_id denylist)
+	var revocationStore service.RevocationStore
+	if appServer.Revocation.Enabled {
+		revocationStore = repository.NewRevocationRepository(database,
+															 appServer.AwsService.DynamoTableName,
+															 &logger)
+	}
+
+	// Load the user scopes store (rbac)
+	var credentialScopeStore service.CredentialScopeStore
+	if appServer.Rbac.Enabled {
+		credentialScopeStore = repository.NewCredentialScopeRepository(database,
+																	   appServer.AwsService.DynamoTableName,
+																	   &logger)
+	}
+
+	// Load the HS256 secret provider
+	var secretProvider service.SecretProvider
+	if appServer.Application.AuthenticationModel == "HS256" || appServer.Application.AuthenticationModel == "MIXED" {
+		switch appServer.HmacSecret.Provider {
+		case "file":
+			secretProvider = secret.NewFileSecretProvider(appServer.HmacSecret.FilePath,
+														  &logger)
+		default:
+			secretProvider = secret.NewSecretsManagerProvider(&awsCfg,
+															  appServer.AwsService.SecretName,
+															  &logger)
+		}
+	}
+
+	// Load the remote jwks source (oidc discovery)
+	var keySource service.KeySource
+	if appServer.Oidc.IssuerUrl != "" {
+		keySource = jwks.NewOidcJwksFetcher(appServer.Oidc.IssuerUrl,
+											nil,
+											&logger)
+	}
+
+	// Load the trusted issuers (each one with its own key source)
+	keySources := make(map[string]service.KeySource)
+	if appServer.AwsService.TrustConfigSource != "" {
+		raw, err := sourceLoader.Load(ctx, appServer.AwsService.TrustConfigSource)
+		if err != nil{
+			return nil, fmt.Errorf("configuration load trust config: %w", err)
+		}
+
+		trustConfig := model.TrustConfig{}
+		if err := source.DecodeJson(raw, &trustConfig); err != nil {
+			return nil, fmt.Errorf("configuration parse trust config: %w", err)
+		}
+		appServer.TrustConfig = &trustConfig
+
+		jwksLoader := jwks.NewJwksLoader(sourceLoader, &logger)
+		for _, trustedIssuer := range trustConfig.Issuers {
+			switch trustedIssuer.KeySource {
+			case "local", "":
+			case "oidc":
+				keySources[trustedIssuer.Issuer] = jwks.NewOidcJwksFetcher(trustedIssuer.Issuer,
+																		   nil,
+																		   &logger)
+			default:
+				keySources[trustedIssuer.Issuer] = jwks.NewJwksSource(jwksLoader, trustedIssuer.KeySource)
+			}
+		}
+	}
+
+	// Load the scope policy (route rules)
+	if appServer.AwsService.ScopePolicySource != "" {
+		raw, err := sourceLoader.Load(ctx, appServer.AwsService.ScopePolicySource)
+		if err != nil{
+			return nil, fmt.Errorf("configuration load scope policy: %w", err)
+		}
+
+		scopePolicy := model.ScopePolicy{}
+		if err := source.Decode(raw, &scopePolicy); err != nil {
+			return nil, fmt.Errorf("configuration parse scope policy: %w", err)
+		}
+		appServer.ScopePolicy = &scopePolicy
+	}
+
+	// Load the introspection client (opaque tokens)
+	var introspector service.Introspector
+	if appServer.Introspection.Endpoint != "" {
+		clientSecret := appServer.Introspection.ClientSecret
+		if appServer.Introspection.ClientSecretName != "" {
+			clientSecret, err = secret.NewSecretsManagerProvider(&awsCfg,
+																 appServer.Introspection.ClientSecretName,
+																 &logger).GetSecret(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("configuration get introspection client secret: %w", err)
+			}
+		}
+		introspector = introspection.NewIntrospectionClient(appServer.Introspection.Endpoint,
+															 appServer.Introspection.ClientId,
+															 clientSecret,
+															 nil,
+															 &logger)
+	}
+
+	// Load the ocsp client (responder of the client certificate AIA)
+	var ocspResponder service.OcspResponder
+	if appServer.ClientCertValidation.OcspEnabled {
+		if rsaKey.CaCert == "" {
+			return nil, fmt.Errorf("configuration ocsp: CA_CERT_FILE_KEY is required with OCSP_CHECK")
+		}
+		ocspResponder = ocsp.NewOcspClient(appServer.ClientCertValidation.OcspTimeout,
+										   &logger)
+	}
+
 	return &AppContext{
 		Logger:         logger,
 		Server:         appServer,
 		TracerProvider: tracerProvider,
+		RevocationStore: revocationStore,
+		CredentialScopeStore: credentialScopeStore,
+		SecretProvider:	secretProvider,
+		KeySource:		keySource,
+		KeySources:		keySources,
+		Introspector:	introspector,
+		CrlSource:		crlSource,
+		OcspResponder:	ocspResponder,
 	}, nil
 }
 
@@ -244,6 +469,65 @@ func main (){
 
 	// Wire 
 	workerService := service.NewWorkerService(appCtx.Server, &appCtx.Logger, appCtx.TracerProvider)
+	if appCtx.RevocationStore != nil {
+		workerService.SetRevocationStore(appCtx.RevocationStore)
+	}
+	if appCtx.CredentialScopeStore != nil {
+		workerService.SetCredentialScopeStore(appCtx.CredentialScopeStore)
+	}
+	if appCtx.Introspector != nil {
+		workerService.SetIntrospector(appCtx.Introspector)
+	}
+	if appCtx.KeySource != nil {
+		if err := workerService.SetKeySource(ctx, appCtx.KeySource); err != nil {
+			appCtx.Logger.Fatal().
+				Err(err).
+				Msg("FAILED to load the remote jwks")
+		}
+	}
+	if appCtx.SecretProvider != nil {
+		if err := workerService.SetSecretProvider(ctx, appCtx.SecretProvider); err != nil {
+			appCtx.Logger.Fatal().
+				Err(err).
+				Msg("FAILED to load the HS256 keys")
+		}
+	}
+	if appCtx.CrlSource != nil {
+		if err := workerService.SetCrlSource(ctx, appCtx.CrlSource); err != nil {
+			appCtx.Logger.Fatal().
+				Err(err).
+				Msg("FAILED to load the crl")
+		}
+	}
+	if appCtx.Server.ClientCertValidation.ChainValidation {
+		if err := workerService.SetClientCertTrust(ctx); err != nil {
+			appCtx.Logger.Fatal().
+				Err(err).
+				Msg("FAILED to load the client certificate trust bundle")
+		}
+	}
+	if appCtx.OcspResponder != nil {
+		if err := workerService.SetOcspResponder(ctx, appCtx.OcspResponder); err != nil {
+			appCtx.Logger.Fatal().
+				Err(err).
+				Msg("FAILED to load the ocsp responder")
+		}
+	}
+	if appCtx.Server.TrustConfig != nil {
+		if err := workerService.SetTrustConfig(ctx, appCtx.Server.TrustConfig, appCtx.KeySources); err != nil {
+			appCtx.Logger.Fatal().
+				Err(err).
+				Msg("FAILED to load the trust config")
+		}
+	}
+
+	if appCtx.Server.ScopePolicy != nil {
+		if err := workerService.SetScopePolicy(ctx, appCtx.Server.ScopePolicy); err != nil {
+			appCtx.Logger.Fatal().
+				Err(err).
+				Msg("FAILED to load the scope policy")
+		}
+	}
 
 	// Create Lambda Server										   
 	lambdaServer := server.NewLambdaServer(appCtx.Server,
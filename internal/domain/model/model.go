@@ -2,7 +2,11 @@ package model
 
 import (
 	"time"
+	"strings"
+	"encoding/json"
 	"crypto/rsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	
 	"github.com/golang-jwt/jwt/v5"
 	go_core_otel_trace "github.com/eliezerraj/go-core/v2/otel/trace"
@@ -12,6 +16,18 @@ type AppServer struct {
 	Application 		*Application	`json:"application"`
 	AwsService			*AwsService		`json:"aws_service"`
 	RsaKey				*RsaKey			`json:"rsa_key"`
+	TokenValidation		map[string]*TokenValidation `json:"token_validation"`
+	ClaimValidation		*ClaimValidation `json:"claim_validation"`
+	Revocation			*Revocation		`json:"revocation"`
+	Rbac				*Rbac			`json:"rbac,omitempty"`
+	HmacSecret			*HmacSecret		`json:"hmac_secret"`
+	Introspection		*Introspection	`json:"introspection,omitempty"`
+	TokenEncryption		*TokenEncryption `json:"token_encryption,omitempty"`
+	ClientCertValidation	*ClientCertValidation `json:"client_cert_validation,omitempty"`
+	SpiffeAuthorization	*SpiffeAuthorization `json:"spiffe_authorization,omitempty"`
+	Oidc				*Oidc			`json:"oidc"`
+	TrustConfig			*TrustConfig	`json:"trust_config,omitempty"`
+	ScopePolicy			*ScopePolicy	`json:"scope_policy,omitempty"`
 	EnvTrace			*go_core_otel_trace.EnvTrace	`json:"env_trace"`
 }
 
@@ -40,7 +56,183 @@ type AwsService struct {
 	FilePathRSA			string `json:"path_rsa_key,omitempty"`
 	FileNameRSAPrivKey	string `json:"file_name_rsa_private_key,omitempty"`
 	FileNameRSAPubKey	string `json:"file_name_rsa_public_key,omitempty"`
+	FileNameECPubKey	string `json:"file_name_ec_public_key,omitempty"`
+	FileNameEdPubKey	string `json:"file_name_ed_public_key,omitempty"`
 	FileNameCrlKey		string `json:"file_name_crl_key"`
+	FileNameCaCertKey	string `json:"file_name_ca_cert_key,omitempty"`
+	JwksSource			string `json:"jwks_source,omitempty"`
+	TrustConfigSource	string `json:"trust_config_source,omitempty"`
+	ScopePolicySource	string `json:"scope_policy_source,omitempty"`
+}
+
+// TokenValidation are the token rules of an authentication model (RSA, ECDSA, EDDSA, HS256)
+// The exp claim is always required, iat and nbf are optional
+type TokenValidation struct {
+	AllowedAlgorithms	[]string `json:"allowed_algorithms"`
+	Leeway				time.Duration `json:"leeway"`
+	RequireIssuedAt		bool `json:"require_iat"`
+	RequireNotBefore	bool `json:"require_nbf"`
+	MaxTokenAge			time.Duration `json:"max_token_age,omitempty"`		// now - iat
+	MaxTokenLifetime	time.Duration `json:"max_token_lifetime,omitempty"`	// exp - iat
+}
+
+// ClaimValidation are the expected claims, empty means not checked
+type ClaimValidation struct {
+	Issuers				[]string `json:"issuers,omitempty"`
+	Audiences			map[string][]string `json:"audiences,omitempty"` // api id (or * for any api) => audiences
+	TokenUse			[]string `json:"token_use,omitempty"`
+	ScopeClaims			[]string `json:"scope_claims,omitempty"` // claim names with the scopes (ex: scope, scp)
+}
+
+// Revocation is the jwt_id denylist check (stored in the dynamo table)
+type Revocation struct {
+	Enabled				bool	`json:"enabled"`
+	CacheTTL			time.Duration `json:"cache_ttl"`
+	CacheSize			int		`json:"cache_size"`
+}
+
+// Rbac keeps only the token scopes also granted by the current user scopes (CredentialScope) of the dynamo table
+type Rbac struct {
+	Enabled				bool	`json:"enabled"`
+	CacheTTL			time.Duration `json:"cache_ttl"`
+	CacheSize			int		`json:"cache_size"`
+}
+
+// ClientCertValidation is the validation of the mTLS client certificate (RequestContext.Identity.ClientCert)
+// When CertBoundRequired a token without cnf (RFC 8705) is denied
+type ClientCertValidation struct {
+	CertBoundRequired	bool	`json:"cert_bound_required"`
+	CrlRefreshInterval	time.Duration `json:"crl_refresh_interval"` // the crl is also reloaded when the NextUpdate is reached
+	OcspEnabled			bool	`json:"ocsp_enabled"`
+	OcspFailOpen		bool	`json:"ocsp_fail_open"` // allow when the responder can not be reached
+	OcspTimeout			time.Duration `json:"ocsp_timeout"`
+	OcspCacheTTL		time.Duration `json:"ocsp_cache_ttl"` // used when the response has no NextUpdate
+	OcspCacheSize		int		`json:"ocsp_cache_size"`
+	ChainValidation		bool	`json:"chain_validation"` // chain to the RsaKey.CaCert, validity, key usage, client auth and name constraints
+}
+
+// SpiffeAuthorization is the service-to-service authorization by the SPIFFE ID of the client certificate (no jwt)
+// Allowlist: the arn path (or * for any path) => SPIFFE IDs (spiffe://td/path) or trust domains (spiffe://td)
+type SpiffeAuthorization struct {
+	Enabled				bool	`json:"enabled"`
+	Allowlist			map[string][]string `json:"allowlist,omitempty"`
+}
+
+// ClientCertInfo is the validated mTLS client certificate exposed in the authorizer context
+type ClientCertInfo struct {
+	SubjectDN			string	`json:"subject_dn"`
+	IssuerDN			string	`json:"issuer_dn"`
+	SerialNumber		string	`json:"serial_number"`
+	DnsNames			[]string `json:"dns_names,omitempty"`
+	EmailAddresses		[]string `json:"email_addresses,omitempty"`
+	IpAddresses			[]string `json:"ip_addresses,omitempty"`
+	Uris				[]string `json:"uris,omitempty"`
+	SpiffeId			string	`json:"spiffe_id,omitempty"`
+}
+
+// TokenEncryption is the nested jwe (RSA-OAEP-256 + A256GCM) decrypted by the RsaKey.RsaPrivate
+// When required a token only signed (jws) is denied
+type TokenEncryption struct {
+	Required			bool	`json:"required"`
+}
+
+// Introspection is the RFC 7662 endpoint used to validate the opaque (non jwt) access tokens
+// The client secret is informed in the env or loaded from the secrets manager (ClientSecretName)
+type Introspection struct {
+	Endpoint			string	`json:"endpoint,omitempty"`
+	ClientId			string	`json:"client_id,omitempty"`
+	ClientSecret		string	`json:"-"`
+	ClientSecretName	string	`json:"client_secret_name,omitempty"`
+	CacheTTL			time.Duration `json:"cache_ttl"` // max ttl of a active token (bounded by the exp)
+	NegativeCacheTTL	time.Duration `json:"negative_cache_ttl"`
+	CacheSize			int		`json:"cache_size"`
+}
+
+// IntrospectionResponse is the RFC 7662 introspection response
+type IntrospectionResponse struct {
+	Active				bool	`json:"active"`
+	Scope				string	`json:"scope,omitempty"`
+	ClientId			string	`json:"client_id,omitempty"`
+	Username			string	`json:"username,omitempty"`
+	TokenType			string	`json:"token_type,omitempty"`
+	Exp					int64	`json:"exp,omitempty"`
+	Iat					int64	`json:"iat,omitempty"`
+	Nbf					int64	`json:"nbf,omitempty"`
+	Sub					string	`json:"sub,omitempty"`
+	Aud					jwt.ClaimStrings `json:"aud,omitempty"`
+	Iss					string	`json:"iss,omitempty"`
+	Jti					string	`json:"jti,omitempty"`
+	Cnf					*Confirmation `json:"cnf,omitempty"`
+}
+
+// HmacSecret is where the HS256 keys are loaded (secretsmanager uses the AwsService.SecretName)
+type HmacSecret struct {
+	Provider			string	`json:"provider"` // secretsmanager or file
+	FilePath			string	`json:"file_path,omitempty"`
+	RefreshInterval		time.Duration `json:"refresh_interval"`
+}
+
+// HmacKeys is the json secret with versioned keys, the current kid is used when the token has no kid
+// Ex: {"current_kid":"v2","keys":{"v1":"old-secret","v2":"new-secret"}}
+type HmacKeys struct {
+	CurrentKid			string	`json:"current_kid"`
+	Keys				map[string]string `json:"keys"`
+}
+
+// Oidc is the issuer used to discover the remote jwks (/.well-known/openid-configuration)
+type Oidc struct {
+	IssuerUrl			string	`json:"issuer_url,omitempty"`
+	DefaultCacheTTL		time.Duration `json:"default_cache_ttl"`	// when the jwks response has no cache headers
+	MinCacheTTL			time.Duration `json:"min_cache_ttl"`
+	MaxCacheTTL			time.Duration `json:"max_cache_ttl"`
+	UnknownKidInterval	time.Duration `json:"unknown_kid_interval"` // min interval between fetches by a unknown kid
+}
+
+type OidcConfiguration struct {
+	Issuer				string	`json:"issuer"`
+	JwksUri				string	`json:"jwks_uri"`
+	IntrospectionEndpoint	string	`json:"introspection_endpoint,omitempty"`
+}
+
+// TrustConfig is the list of trusted issuers, the issuer is choosen by the token iss
+type TrustConfig struct {
+	Issuers				[]TrustedIssuer `json:"issuers"`
+}
+
+// TrustedIssuer is the key source, algorithms, audiences and claim mapping of a issuer
+// key_source: local (the keys of this lambda), oidc (discovery by the issuer url) or a jwks source (s3://, https://, file://)
+type TrustedIssuer struct {
+	Issuer				string	`json:"issuer"`
+	KeySource			string	`json:"key_source"`
+	AllowedAlgorithms	[]string `json:"allowed_algorithms"`
+	Audiences			[]string `json:"audiences,omitempty"`
+	ClaimMapping		ClaimMapping `json:"claim_mapping,omitempty"`
+}
+
+// ClaimMapping is the claim name of each JwtData field, empty means the default claim
+type ClaimMapping struct {
+	Scope				string	`json:"scope,omitempty"`
+	Username			string	`json:"username,omitempty"`
+	Tenant				string	`json:"tenant,omitempty"`
+}
+
+// ScopePolicy is the route rules of the scope validation (YAML or JSON), a request without a matching rule is denied
+// superscopes: scopes allowed in all the routes, [admin] when not informed
+// routes: optional, without routes the default rules are used with the superscopes and the scope_hierarchy
+// scope_hierarchy: the scopes implied by a scope (ex: account:admin => account:write => account:read)
+type ScopePolicy struct {
+	Superscopes			[]string	`json:"superscopes,omitempty"`
+	ScopeHierarchy		map[string][]string `json:"scope_hierarchy,omitempty"`
+	Routes				[]RouteRule `json:"routes,omitempty"`
+}
+
+// RouteRule is the methods of a path (ANY means all the methods) and the scopes allowed (any of them)
+// condition: optional CEL expression also required to allow (ex: claims.tier == "tier1")
+type RouteRule struct {
+	Path				string		`json:"path"`
+	Methods				[]string	`json:"methods"`
+	Scopes				[]string	`json:"scopes"`
+	Condition			string		`json:"condition,omitempty"`
 }
 
 type Credential struct {
@@ -75,6 +267,19 @@ type RsaKey struct{
 	CaCert			string	`json:"ca_cert"` 
 	RsaPrivate 		*rsa.PrivateKey `json:"rsa_private"`
 	RsaPublic 		*rsa.PublicKey	`json:"rsa_public"`
+	EcPublic 		*ecdsa.PublicKey	`json:"ec_public,omitempty"`
+	EdPublic 		ed25519.PublicKey	`json:"ed_public,omitempty"`
+	Jwks			*Jwks			`json:"jwks,omitempty"`
+}
+
+type TokenRevoked struct {
+	ID				string		`json:"ID"`
+	SK				string		`json:"SK"`
+	JwtId			string		`json:"jwt_id,omitempty"`
+	User			string		`json:"user,omitempty"`
+	Reason			string		`json:"reason,omitempty"`
+	RevokedAt		time.Time	`json:"revoked_at,omitempty"`
+	TimeToLive		int64		`json:"TimeToLive,omitempty"` // epoch seconds (token exp)
 }
 
 type Authentication struct {
@@ -92,19 +297,68 @@ type JwtData struct {
 	Kid				string 	`json:"kid"`
 	Tier			string 	`json:"tier"` 			// use in plan usage rate-limit
 	ApiAccessKey	string 	`json:"api_access_key"` // use in plan usage rate-limit
-	Scope	  		[]string `json:"scope"`
+	Tenant			string 	`json:"tenant_id,omitempty"`
+	Scope	  		ScopeList `json:"scope"`
+	Cnf				*Confirmation `json:"cnf,omitempty"`
+	SpiffeId		string	`json:"-"` // principal of the client certificate, never read from a token
+	RawClaims		jwt.MapClaims `json:"-"` // all the claims of the verified token (ex: scp), nil without a jwt
 	jwt.RegisteredClaims
 }
 
+// Confirmation is the token proof of possession (RFC 7800), x5t#S256 is the client certificate thumbprint (RFC 8705)
+type Confirmation struct {
+	X5tS256			string	`json:"x5t#S256,omitempty"`
+}
+
+// ScopeList is the token scope, a json array or a OAuth2 space separated string (RFC 6749)
+type ScopeList []string
+
+// About decode the scope as array or space separated string
+func (s *ScopeList) UnmarshalJSON(data []byte) error {
+	var scope string
+	if err := json.Unmarshal(data, &scope); err == nil {
+		*s = strings.Fields(scope)
+		return nil
+	}
+
+	var scopes []string
+	if err := json.Unmarshal(data, &scopes); err != nil {
+		return err
+	}
+	*s = scopes
+	return nil
+}
+
 type Jwks struct{
 	JwtKeyInfo	[]JwtKeyInfo `json:"keys"`
 }
 
 type JwtKeyInfo struct{
 	Type		string 	`json:"kty"`
-	Algorithm	string 	`json:"alg"`
+	Algorithm	string 	`json:"alg,omitempty"`
+	Use			string 	`json:"use,omitempty"`
 	Kid			string 	`json:"kid"`
-	NBase64		string 	`json:"n"`
+	NBase64		string 	`json:"n,omitempty"`
+	EBase64		string 	`json:"e,omitempty"`
+	Crv			string 	`json:"crv,omitempty"`
+	XBase64		string 	`json:"x,omitempty"`
+	YBase64		string 	`json:"y,omitempty"`
+}
+
+type MethodArn struct {
+	Region		string
+	Account		string
+	ApiId		string
+	Stage		string
+	Method		string
+	Path		string
+}
+
+// RequestData is the request attributes seen by the route conditions
+type RequestData struct {
+	Headers				map[string]string
+	QueryParameters		map[string]string
+	SourceIp			string
 }
 
 type PolicyData struct {
@@ -113,4 +367,6 @@ type PolicyData struct {
 	MethodArn		string
 	UsageIdentifierKey	*string		
 	Message			string		
+	Reason			string
+	ClientCert		*ClientCertInfo
 }
\ No newline at end of file
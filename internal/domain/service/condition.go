@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"time"
+	"context"
+	"strings"
+	"encoding/json"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// conditionCostLimit bounds the cost of a route condition evaluation
+const conditionCostLimit = 10000
+
+// About create the CEL environment of the route conditions
+// claims: the token claims, request: method, path, headers (lower case), query and source_ip,
+// arn: the method arn parsed (region, account, api_id, stage, method, path) and now: the request time
+func newConditionEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("arn", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("now", cel.TimestampType),
+	)
+}
+
+// About compile a route condition, it must be a bool expression
+func compileCondition(env *cel.Env, path string, condition string) (cel.Program, error) {
+	ast, issues := env.Compile(condition)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("%w: scope policy route %s condition invalid: %v", erro.ErrBadRequest, path, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("%w: scope policy route %s condition must be bool, not %s", erro.ErrBadRequest, path, ast.OutputType())
+	}
+
+	program, err := env.Program(ast,
+								cel.CostLimit(conditionCostLimit),
+								cel.InterruptCheckFrequency(100))
+	if err != nil {
+		return nil, fmt.Errorf("%w: scope policy route %s condition invalid: %v", erro.ErrBadRequest, path, err)
+	}
+
+	return program, nil
+}
+
+// About the variables of the route conditions
+// The claims are the verified token claims by their names, without a jwt (ex: introspection) the JwtData json names
+func conditionVars(claims model.JwtData, methodArn *model.MethodArn, requestData *model.RequestData) (map[string]interface{}, error) {
+	claimsVar := map[string]interface{}(claims.RawClaims)
+	if claimsVar == nil {
+		rawClaims, err := json.Marshal(claims)
+		if err != nil {
+			return nil, err
+		}
+		claimsVar = map[string]interface{}{}
+		if err := json.Unmarshal(rawClaims, &claimsVar); err != nil {
+			return nil, err
+		}
+	}
+
+	if requestData == nil {
+		requestData = &model.RequestData{}
+	}
+	headers := make(map[string]string, len(requestData.Headers))
+	for key, value := range requestData.Headers {
+		headers[strings.ToLower(key)] = value
+	}
+	query := requestData.QueryParameters
+	if query == nil {
+		query = map[string]string{}
+	}
+
+	return map[string]interface{}{
+		"claims": claimsVar,
+		"request": map[string]interface{}{
+			"method": methodArn.Method,
+			"path": methodArn.Path,
+			"headers": headers,
+			"query": query,
+			"source_ip": requestData.SourceIp,
+		},
+		"arn": map[string]string{
+			"region": methodArn.Region,
+			"account": methodArn.Account,
+			"api_id": methodArn.ApiId,
+			"stage": methodArn.Stage,
+			"method": methodArn.Method,
+			"path": methodArn.Path,
+		},
+		"now": time.Now(),
+	}, nil
+}
+
+// About evaluate the route condition, a evaluation error (ex: header not informed) denies the request
+func (w *WorkerService) conditionValidation(ctx context.Context,
+											route *routeRule,
+											claims model.JwtData,
+											arn string,
+											requestData *model.RequestData) bool {
+	if route.condition == nil {
+		return true
+	}
+
+	methodArn, err := parseMethodArn(arn)
+	if err != nil {
+		return false
+	}
+
+	vars, err := conditionVars(claims, methodArn, requestData)
+	if err != nil {
+		w.logger.Error().
+			Ctx(ctx).
+			Err(err).
+			Msg("erro prepare the route condition variables")
+		return false
+	}
+
+	result, _, err := route.condition.ContextEval(ctx, vars)
+	if err != nil {
+		w.logger.Warn().
+			Ctx(ctx).
+			Err(err).
+			Str("path", route.path).
+			Msg("route condition evaluation FAILED")
+		return false
+	}
+
+	if allowed, ok := result.Value().(bool); !ok || !allowed {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("path", route.path).
+			Msg("route condition NOT SATISFIED")
+		return false
+	}
+
+	return true
+}
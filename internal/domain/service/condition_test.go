@@ -0,0 +1,77 @@
+package service
+
+import (
+	"time"
+	"context"
+	"testing"
+	"crypto/rand"
+	"crypto/rsa"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+func TestConditionClaims(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestWorkerService(t, &model.AppServer{
+		RsaKey: &model.RsaKey{RsaPublic: &key.PublicKey, Kid: "k1"},
+		TokenValidation: map[string]*model.TokenValidation{"RSA": {AllowedAlgorithms: []string{"RS256"}}},
+	})
+	if err := w.SetScopePolicy(context.Background(), &model.ScopePolicy{
+		Routes: []model.RouteRule{
+			{Path: "plan", Methods: []string{"GET"}, Scopes: []string{"plan:read"}, Condition: `claims.plan == "gold"`},
+			{Path: "org", Methods: []string{"GET"}, Scopes: []string{"plan:read"}, Condition: `claims.org.region == "br"`},
+			{Path: "tenant", Methods: []string{"GET"}, Scopes: []string{"plan:read"}, Condition: `claims.tenant_id == "tenant-01"`},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		claims["scope"] = "plan:read"
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "k1"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name		string
+		claims		jwt.MapClaims
+		arn			string
+		want		bool
+	}{
+		{name: "claim not in JwtData", claims: jwt.MapClaims{"plan": "gold"}, arn: "GET/plan", want: true},
+		{name: "claim not in JwtData other value", claims: jwt.MapClaims{"plan": "silver"}, arn: "GET/plan", want: false},
+		{name: "nested claim", claims: jwt.MapClaims{"org": map[string]interface{}{"region": "br"}}, arn: "GET/org", want: true},
+		{name: "claim missing", claims: jwt.MapClaims{}, arn: "GET/plan", want: false},
+		{name: "JwtData claim by the token name", claims: jwt.MapClaims{"tenant_id": "tenant-01"}, arn: "GET/tenant", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := w.tokenValidationRSA(context.Background(), sign(tt.claims))
+			if err != nil {
+				t.Fatalf("tokenValidationRSA() error = %v", err)
+			}
+			if got := w.ScopeValidation(context.Background(), *claims, testArnPrefix + tt.arn, &model.RequestData{}); got != tt.want {
+				t.Fatalf("ScopeValidation(%s) = %v, want %v", tt.arn, got, tt.want)
+			}
+		})
+	}
+
+	// without a jwt (ex: introspection) the claims are the JwtData json names
+	claims := model.JwtData{Tenant: "tenant-01", Scope: model.ScopeList{"plan:read"}}
+	if !w.ScopeValidation(context.Background(), claims, testArnPrefix + "GET/tenant", &model.RequestData{}) {
+		t.Fatal("ScopeValidation(GET/tenant) without raw claims = false, want true")
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"sync"
+	"time"
+	"bytes"
+	"context"
+	"crypto/x509"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/shared/certificate"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CrlSource gets the raw crl (pem X509 CRL or der)
+type CrlSource interface {
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// crlRefresh controls the periodic reload of the crl
+type crlRefresh struct {
+	mutex			sync.Mutex
+	crlSource		CrlSource
+	caCerts			[]*x509.Certificate
+	revocationList	*x509.RevocationList
+	revoked			map[string]bool // serial number (hex)
+	refreshInterval	time.Duration
+	loadedAt		time.Time
+}
+
+// About set the crl source and load the crl, the crl signature is verified by the RsaKey.CaCert
+func (w *WorkerService) SetCrlSource(ctx context.Context, crlSource CrlSource) error {
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","SetCrlSource").Send()
+
+	if w.appServer.RsaKey == nil || w.appServer.RsaKey.CaCert == "" {
+		return erro.ErrCrlCheck
+	}
+
+	caCerts, err := certificate.ParsePemToCertx509List(&w.appServer.RsaKey.CaCert, w.logger)
+	if err != nil {
+		return err
+	}
+
+	var refreshInterval time.Duration
+	if w.appServer.ClientCertValidation != nil {
+		refreshInterval = w.appServer.ClientCertValidation.CrlRefreshInterval
+	}
+
+	w.crlRefresh = &crlRefresh{
+		crlSource: crlSource,
+		caCerts: caCerts,
+		refreshInterval: refreshInterval,
+	}
+
+	return w.loadCrl(ctx)
+}
+
+// About reload the crl when CRL_REFRESH_INTERVAL elapsed or the crl NextUpdate passed, a failed reload keeps the previous crl
+func (w *WorkerService) refreshCrl(ctx context.Context) {
+	w.crlRefresh.mutex.Lock()
+	stale := (w.crlRefresh.refreshInterval > 0 && time.Since(w.crlRefresh.loadedAt) > w.crlRefresh.refreshInterval) ||
+			 (w.crlRefresh.revocationList != nil && crlExpired(w.crlRefresh.revocationList))
+	// retry a failed reload only after a minute, avoiding load the crl in every request
+	stale = stale && time.Since(w.crlRefresh.loadedAt) > time.Minute
+	w.crlRefresh.mutex.Unlock()
+
+	if stale {
+		if err := w.loadCrl(ctx); err != nil {
+			w.logger.Error().
+				Ctx(ctx).
+				Err(err).
+				Msg("erro refresh crl, the current crl is kept")
+		}
+	}
+}
+
+// About load the crl, it must be signed by a ca cert and not expired (NextUpdate)
+func (w *WorkerService) loadCrl(ctx context.Context) error {
+	w.crlRefresh.mutex.Lock()
+	defer w.crlRefresh.mutex.Unlock()
+
+	// set before the s3 read, so a failed read is retried by refreshCrl only after a minute
+	w.crlRefresh.loadedAt = time.Now()
+
+	raw, err := w.crlRefresh.crlSource.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	crl := string(raw)
+	revocationList, err := certificate.ParsePemToCrl(&crl, w.logger)
+	if err != nil {
+		return err
+	}
+
+	signed := false
+	for _, caCert := range w.crlRefresh.caCerts {
+		if bytes.Equal(caCert.RawSubject, revocationList.RawIssuer) && revocationList.CheckSignatureFrom(caCert) == nil {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		w.logger.Error().
+			Ctx(ctx).
+			Str("crl_issuer", revocationList.Issuer.String()).
+			Msg("crl signature INVALID")
+		return erro.ErrCrlCheck
+	}
+
+	if crlExpired(revocationList) {
+		w.logger.Error().
+			Ctx(ctx).
+			Time("next_update", revocationList.NextUpdate).
+			Msg("crl EXPIRED")
+		return erro.ErrCrlCheck
+	}
+
+	revoked := make(map[string]bool, len(revocationList.RevokedCertificateEntries))
+	for _, entry := range revocationList.RevokedCertificateEntries {
+		revoked[entry.SerialNumber.Text(16)] = true
+	}
+
+	w.crlRefresh.revocationList = revocationList
+	w.crlRefresh.revoked = revoked
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("crl_issuer", revocationList.Issuer.String()).
+		Time("next_update", revocationList.NextUpdate).
+		Int("revoked", len(revoked)).
+		Msg("crl loaded")
+
+	return nil
+}
+
+// About check if the crl NextUpdate was reached
+func crlExpired(revocationList *x509.RevocationList) bool {
+	return !revocationList.NextUpdate.IsZero() && time.Now().After(revocationList.NextUpdate)
+}
+
+// About check if the mTLS client certificate serial is in the crl (fail closed when the crl is expired)
+// A certificate of another issuer is not covered by the crl
+func (w *WorkerService) ClientCertRevocationValidation(ctx context.Context, certX509 *x509.Certificate) error {
+	if w.crlRefresh == nil || certX509 == nil {
+		return nil
+	}
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","ClientCertRevocationValidation").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.ClientCertRevocationValidation", trace.SpanKindServer)
+	defer span.End()
+
+	w.refreshCrl(ctx)
+
+	w.crlRefresh.mutex.Lock()
+	revocationList, revoked := w.crlRefresh.revocationList, w.crlRefresh.revoked
+	w.crlRefresh.mutex.Unlock()
+
+	if revocationList == nil || crlExpired(revocationList) {
+		w.logger.Warn().
+			Ctx(ctx).
+			Msg("crl NOT VALID, revocation can not be checked")
+		return erro.ErrCrlCheck
+	}
+
+	if !bytes.Equal(certX509.RawIssuer, revocationList.RawIssuer) {
+		w.logger.Debug().
+			Ctx(ctx).
+			Str("cert_issuer", certX509.Issuer.String()).
+			Msg("client certificate issuer not covered by the crl")
+		return nil
+	}
+
+	if revoked[certX509.SerialNumber.Text(16)] {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("serial_number", certX509.SerialNumber.Text(16)).
+			Str("subject", certX509.Subject.String()).
+			Msg("client certificate REVOKED")
+		return erro.ErrCertRevoked
+	}
+
+	return nil
+}
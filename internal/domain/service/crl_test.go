@@ -0,0 +1,147 @@
+package service
+
+import (
+	"time"
+	"errors"
+	"context"
+	"testing"
+	"math/big"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// testCrlSource returns the crl created in the test, the fixture in assets/certs is not used (it expires)
+type testCrlSource struct {
+	raw		[]byte
+	err		error
+}
+
+func (s *testCrlSource) Load(ctx context.Context) ([]byte, error) {
+	return s.raw, s.err
+}
+
+// crl creates a pem crl signed by the CA with the revoked serials
+func (c *testCa) crl(t *testing.T, nextUpdate time.Time, serials ...int64) []byte {
+	t.Helper()
+
+	template := &x509.RevocationList{
+		Number: big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, serial := range serials {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber: big.NewInt(serial),
+			RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, c.cert, c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+func newCrlWorkerService(t *testing.T, ca *testCa) *WorkerService {
+	t.Helper()
+
+	return newTestWorkerService(t, &model.AppServer{
+		RsaKey: &model.RsaKey{CaCert: ca.pem()},
+		ClientCertValidation: &model.ClientCertValidation{CrlRefreshInterval: time.Hour},
+	})
+}
+
+func TestSetCrlSource(t *testing.T) {
+	ca := newTestCa(t, "ca-01")
+	otherCa := newTestCa(t, "ca-02")
+
+	tests := []struct {
+		name		string
+		source		*testCrlSource
+		wantErr		error
+	}{
+		{name: "valid", source: &testCrlSource{raw: ca.crl(t, time.Now().Add(time.Hour), 11)}},
+		{name: "der", source: &testCrlSource{raw: func() []byte { block, _ := pem.Decode(ca.crl(t, time.Now().Add(time.Hour))); return block.Bytes }()}},
+		{name: "expired", source: &testCrlSource{raw: ca.crl(t, time.Now().Add(-time.Minute), 11)}, wantErr: erro.ErrCrlCheck},
+		{name: "signed by other ca", source: &testCrlSource{raw: otherCa.crl(t, time.Now().Add(time.Hour), 11)}, wantErr: erro.ErrCrlCheck},
+		{name: "source error", source: &testCrlSource{err: erro.ErrNotFound}, wantErr: erro.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newCrlWorkerService(t, ca)
+
+			err := w.SetCrlSource(context.Background(), tt.source)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetCrlSource() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetCrlSourceWithoutCaCert(t *testing.T) {
+	w := newTestWorkerService(t, &model.AppServer{})
+
+	if err := w.SetCrlSource(context.Background(), &testCrlSource{}); !errors.Is(err, erro.ErrCrlCheck) {
+		t.Fatalf("SetCrlSource() error = %v, want %v", err, erro.ErrCrlCheck)
+	}
+}
+
+func TestClientCertRevocationValidation(t *testing.T) {
+	ca := newTestCa(t, "ca-01")
+	otherCa := newTestCa(t, "ca-02")
+
+	w := newCrlWorkerService(t, ca)
+	if err := w.SetCrlSource(context.Background(), &testCrlSource{raw: ca.crl(t, time.Now().Add(time.Hour), 11, 12)}); err != nil {
+		t.Fatalf("SetCrlSource() error = %v", err)
+	}
+
+	tests := []struct {
+		name		string
+		cert		*x509.Certificate
+		wantErr		error
+	}{
+		{name: "not revoked", cert: ca.issue(t, 10, testOcspUrl)},
+		{name: "revoked", cert: ca.issue(t, 11, testOcspUrl), wantErr: erro.ErrCertRevoked},
+		{name: "other revoked", cert: ca.issue(t, 12, testOcspUrl), wantErr: erro.ErrCertRevoked},
+		{name: "other issuer same serial", cert: otherCa.issue(t, 11, testOcspUrl)},
+		{name: "no certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := w.ClientCertRevocationValidation(context.Background(), tt.cert)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClientCertRevocationValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientCertRevocationValidationExpired(t *testing.T) {
+	ca := newTestCa(t, "ca-01")
+
+	// the crl expires after loaded, the revocation can not be checked anymore (fail closed)
+	// the NextUpdate is encoded in seconds
+	nextUpdate := time.Now().Add(2 * time.Second).Truncate(time.Second)
+	w := newCrlWorkerService(t, ca)
+	if err := w.SetCrlSource(context.Background(), &testCrlSource{raw: ca.crl(t, nextUpdate, 11)}); err != nil {
+		t.Fatalf("SetCrlSource() error = %v", err)
+	}
+
+	cert := ca.issue(t, 10, testOcspUrl)
+	if err := w.ClientCertRevocationValidation(context.Background(), cert); err != nil {
+		t.Fatalf("ClientCertRevocationValidation() error = %v", err)
+	}
+
+	time.Sleep(time.Until(nextUpdate) + 50*time.Millisecond)
+
+	if err := w.ClientCertRevocationValidation(context.Background(), cert); !errors.Is(err, erro.ErrCrlCheck) {
+		t.Fatalf("ClientCertRevocationValidation() expired crl error = %v, want %v", err, erro.ErrCrlCheck)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// scopeSet is the token scopes expanded by the scope hierarchy
+type scopeSet map[string]bool
+
+// About validate the scope hierarchy and get all the scopes implied by each scope (transitive)
+// A cycle (ex: account:write => account:admin => account:write) must stop the lambda
+func newScopeHierarchy(implies map[string][]string) (map[string][]string, error) {
+	for scope, impliedScopes := range implies {
+		if strings.TrimSpace(scope) == "" {
+			return nil, fmt.Errorf("%w: scope hierarchy with a empty scope", erro.ErrBadRequest)
+		}
+		for _, implied := range impliedScopes {
+			if strings.TrimSpace(implied) == "" {
+				return nil, fmt.Errorf("%w: scope hierarchy %s implies a empty scope", erro.ErrBadRequest, scope)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(implies))
+	hierarchy := make(map[string][]string, len(implies))
+
+	var visit func(scope string, chain []string) error
+	visit = func(scope string, chain []string) error {
+		switch state[scope] {
+		case visiting:
+			return fmt.Errorf("%w: scope hierarchy cycle %s", erro.ErrBadRequest, strings.Join(append(chain, scope), " => "))
+		case visited:
+			return nil
+		}
+		state[scope] = visiting
+
+		closure := []string{}
+		for _, implied := range implies[scope] {
+			if err := visit(implied, append(chain, scope)); err != nil {
+				return err
+			}
+			closure = append(closure, implied)
+			closure = append(closure, hierarchy[implied]...)
+		}
+		slices.Sort(closure)
+		hierarchy[scope] = slices.Compact(closure)
+
+		state[scope] = visited
+		return nil
+	}
+
+	// sorted, so the same config always reports the same cycle
+	scopes := make([]string, 0, len(implies))
+	for scope := range implies {
+		scopes = append(scopes, scope)
+	}
+	slices.Sort(scopes)
+
+	for _, scope := range scopes {
+		if err := visit(scope, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return hierarchy, nil
+}
+
+// About expand the token scopes with the implied scopes, done once by request
+func (p *scopePolicy) expandScopes(scopes model.ScopeList) scopeSet {
+	expanded := make(scopeSet, len(scopes))
+	for _, scope := range scopes {
+		expanded[scope] = true
+		for _, implied := range p.hierarchy[scope] {
+			expanded[implied] = true
+		}
+	}
+	return expanded
+}
+
+// About check if the scope was granted, directly or by a namespace wildcard (ex: account:* grants account:read)
+func (s scopeSet) has(scope string) bool {
+	if s[scope] {
+		return true
+	}
+	for i := len(scope) - 1; i > 0; i-- {
+		if scope[i] == ':' && s[scope[:i+1]+"*"] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"time"
+	"context"
+	"strings"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/shared/cache"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Introspector validates a opaque token in the authorization server (RFC 7662)
+type Introspector interface {
+	Introspect(ctx context.Context, token string) (*model.IntrospectionResponse, error)
+}
+
+// About set the introspector, the result (active or not) is cached by the token hash
+func (w *WorkerService) SetIntrospector(introspector Introspector) {
+	w.introspector = introspector
+
+	cacheSize := 0
+	if w.appServer.Introspection != nil {
+		cacheSize = w.appServer.Introspection.CacheSize
+	}
+	w.introspectionCache = cache.NewCache[*model.JwtData](cacheSize)
+}
+
+// About check if the token is a opaque token to be introspected
+// A jwt (jws) has 3 parts separated by dot, anything else is opaque
+func (w *WorkerService) IsOpaqueToken(bearerToken string) bool {
+	return w.introspector != nil && strings.Count(bearerToken, ".") != 2
+}
+
+// About validate a opaque token by the introspection endpoint
+// A active token is cached until its exp (bounded by the cache ttl) and a inactive one by the negative cache ttl
+// The check fails closed when the endpoint is not reachable
+func (w *WorkerService) TokenIntrospectionValidation(ctx context.Context, bearerToken string) (*model.JwtData, error) {
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","TokenIntrospectionValidation").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.TokenIntrospectionValidation", trace.SpanKindServer)
+	defer span.End()
+
+	// the raw token is never used as cache key
+	hash := sha256.Sum256([]byte(bearerToken))
+	cacheKey := hex.EncodeToString(hash[:])
+
+	claims, ok := w.introspectionCache.Get(cacheKey)
+	if !ok {
+		introspectionResponse, err := w.introspector.Introspect(ctx, bearerToken)
+		if err != nil {
+			w.logger.Error().
+				Ctx(ctx).
+				Err(err).
+				Msg("erro introspect token")
+			return nil, erro.ErrIntrospection
+		}
+
+		claims = introspectionClaims(introspectionResponse)
+		w.introspectionCache.Set(cacheKey, claims, w.introspectionCacheTTL(claims))
+	}
+
+	if claims == nil {
+		w.logger.Warn().
+			Ctx(ctx).
+			Msg("token NOT ACTIVE")
+		return nil, erro.ErrTokenInactive
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt != nil && !now.Before(claims.ExpiresAt.Time) {
+		return nil, erro.ErrTokenExpired
+	}
+	if claims.NotBefore != nil && now.Before(claims.NotBefore.Time) {
+		return nil, erro.ErrTokenNotValidYet
+	}
+
+	// the cached claims are not changed by the next validations
+	tokenClaims := *claims
+	tokenClaims.Scope = append(model.ScopeList{}, claims.Scope...)
+
+	return &tokenClaims, nil
+}
+
+// About the cache ttl of the introspection result
+func (w *WorkerService) introspectionCacheTTL(claims *model.JwtData) time.Duration {
+	introspection := model.Introspection{}
+	if w.appServer.Introspection != nil {
+		introspection = *w.appServer.Introspection
+	}
+
+	if claims == nil {
+		return introspection.NegativeCacheTTL
+	}
+
+	ttl := introspection.CacheTTL
+	if claims.ExpiresAt != nil {
+		if untilExp := time.Until(claims.ExpiresAt.Time); untilExp < ttl {
+			ttl = untilExp
+		}
+	}
+	return ttl
+}
+
+// About map the introspection response to the claims, nil when the token is not active
+func introspectionClaims(introspectionResponse *model.IntrospectionResponse) *model.JwtData {
+	if !introspectionResponse.Active {
+		return nil
+	}
+
+	// only the RFC 7662 fields are mapped, there is no token_use
+	claims := &model.JwtData{
+		ISS: introspectionResponse.Iss,
+		Username: introspectionResponse.Username,
+		Scope: normalizeScopes(strings.Fields(introspectionResponse.Scope)),
+		Cnf: introspectionResponse.Cnf,
+	}
+	claims.Subject = introspectionResponse.Sub
+	claims.Issuer = introspectionResponse.Iss
+	claims.ID = introspectionResponse.Jti
+	claims.Audience = introspectionResponse.Aud
+	if introspectionResponse.Exp > 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Unix(introspectionResponse.Exp, 0))
+	}
+	if introspectionResponse.Iat > 0 {
+		claims.IssuedAt = jwt.NewNumericDate(time.Unix(introspectionResponse.Iat, 0))
+	}
+	if introspectionResponse.Nbf > 0 {
+		claims.NotBefore = jwt.NewNumericDate(time.Unix(introspectionResponse.Nbf, 0))
+	}
+
+	return claims
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"sync"
+	"time"
+	"errors"
+	"context"
+	"testing"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// testIntrospector answers the introspection by token and counts the requests
+type testIntrospector struct {
+	mutex		sync.Mutex
+	responses	map[string]*model.IntrospectionResponse
+	requests	int
+}
+
+func newTestIntrospector() *testIntrospector {
+	return &testIntrospector{responses: make(map[string]*model.IntrospectionResponse)}
+}
+
+// Introspect fails for a token without response, as a unreachable endpoint
+func (i *testIntrospector) Introspect(ctx context.Context, token string) (*model.IntrospectionResponse, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.requests++
+	response, ok := i.responses[token]
+	if !ok {
+		return nil, errors.New("introspection endpoint unavailable")
+	}
+	copied := *response
+	return &copied, nil
+}
+
+func (i *testIntrospector) set(token string, response *model.IntrospectionResponse) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.responses[token] = response
+}
+
+func (i *testIntrospector) count() int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.requests
+}
+
+func newIntrospectionWorkerService(t *testing.T, introspector Introspector) *WorkerService {
+	t.Helper()
+
+	w := newTestWorkerService(t, &model.AppServer{
+		Introspection: &model.Introspection{
+			CacheTTL: time.Hour,
+			NegativeCacheTTL: time.Minute,
+			CacheSize: 10,
+		},
+	})
+	w.SetIntrospector(introspector)
+	return w
+}
+
+func TestTokenIntrospectionValidation(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name		string
+		response	*model.IntrospectionResponse
+		wantErr		error
+	}{
+		{name: "active", response: &model.IntrospectionResponse{Active: true, Scope: "info account:read", Username: "user-01", Sub: "sub-01", Iss: "issuer-01", Jti: "jti-01", Exp: exp}},
+		{name: "active without username", response: &model.IntrospectionResponse{Active: true, Scope: "info", Sub: "sub-01", Exp: exp}},
+		{name: "not active", response: &model.IntrospectionResponse{Active: false, Scope: "info", Exp: exp}, wantErr: erro.ErrTokenInactive},
+		{name: "expired", response: &model.IntrospectionResponse{Active: true, Scope: "info", Exp: time.Now().Add(-time.Minute).Unix()}, wantErr: erro.ErrTokenExpired},
+		{name: "not valid yet", response: &model.IntrospectionResponse{Active: true, Scope: "info", Exp: exp, Nbf: time.Now().Add(time.Minute).Unix()}, wantErr: erro.ErrTokenNotValidYet},
+		{name: "endpoint unavailable", wantErr: erro.ErrIntrospection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			introspector := newTestIntrospector()
+			if tt.response != nil {
+				introspector.set("opaque-token", tt.response)
+			}
+			w := newIntrospectionWorkerService(t, introspector)
+
+			claims, err := w.TokenIntrospectionValidation(context.Background(), "opaque-token")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenIntrospectionValidation() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// only the RFC 7662 fields are mapped
+			if claims.TokenUse != "" || claims.JwtId != "" {
+				t.Fatalf("token_use = %q, jwt_id = %q, want not mapped", claims.TokenUse, claims.JwtId)
+			}
+			if claims.Username != tt.response.Username || claims.Subject != tt.response.Sub {
+				t.Fatalf("username = %q, sub = %q, want %q, %q", claims.Username, claims.Subject, tt.response.Username, tt.response.Sub)
+			}
+			if claims.Issuer != tt.response.Iss || claims.ID != tt.response.Jti {
+				t.Fatalf("iss = %q, jti = %q, want %q, %q", claims.Issuer, claims.ID, tt.response.Iss, tt.response.Jti)
+			}
+			if claims.ExpiresAt == nil || claims.ExpiresAt.Unix() != tt.response.Exp {
+				t.Fatalf("exp = %v, want %d", claims.ExpiresAt, tt.response.Exp)
+			}
+		})
+	}
+}
+
+func TestTokenIntrospectionValidationCache(t *testing.T) {
+	introspector := newTestIntrospector()
+	w := newIntrospectionWorkerService(t, introspector)
+
+	tests := []struct {
+		name			string
+		token			string
+		response		*model.IntrospectionResponse
+		wantErr			error
+		wantRequests	int
+	}{
+		{name: "active", token: "token-01", response: &model.IntrospectionResponse{Active: true, Scope: "info", Exp: time.Now().Add(time.Hour).Unix()}, wantRequests: 1},
+		{name: "active cached", token: "token-01", response: &model.IntrospectionResponse{Active: false}, wantRequests: 1},
+		{name: "not active", token: "token-02", response: &model.IntrospectionResponse{Active: false}, wantErr: erro.ErrTokenInactive, wantRequests: 2},
+		{name: "not active cached", token: "token-02", response: &model.IntrospectionResponse{Active: true, Scope: "info", Exp: time.Now().Add(time.Hour).Unix()}, wantErr: erro.ErrTokenInactive, wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			introspector.set(tt.token, tt.response)
+
+			_, err := w.TokenIntrospectionValidation(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenIntrospectionValidation() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := introspector.count(); got != tt.wantRequests {
+				t.Fatalf("introspection requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestTokenIntrospectionValidationCacheExp(t *testing.T) {
+	introspector := newTestIntrospector()
+	w := newIntrospectionWorkerService(t, introspector)
+
+	// the cache ttl (one hour) is bounded by the exp of the token
+	exp := time.Now().Add(2 * time.Second).Unix()
+	introspector.set("opaque-token", &model.IntrospectionResponse{Active: true, Scope: "info", Exp: exp})
+
+	if _, err := w.TokenIntrospectionValidation(context.Background(), "opaque-token"); err != nil {
+		t.Fatalf("TokenIntrospectionValidation() error = %v", err)
+	}
+
+	// a new token (new exp) for the same value is only seen after the cached exp
+	introspector.set("opaque-token", &model.IntrospectionResponse{Active: true, Scope: "info", Exp: time.Now().Add(time.Hour).Unix()})
+	if _, err := w.TokenIntrospectionValidation(context.Background(), "opaque-token"); err != nil {
+		t.Fatalf("TokenIntrospectionValidation() error = %v", err)
+	}
+	if got := introspector.count(); got != 1 {
+		t.Fatalf("introspection requests before exp = %d, want 1", got)
+	}
+
+	time.Sleep(time.Until(time.Unix(exp, 0)) + 50*time.Millisecond)
+
+	if _, err := w.TokenIntrospectionValidation(context.Background(), "opaque-token"); err != nil {
+		t.Fatalf("TokenIntrospectionValidation() after exp error = %v", err)
+	}
+	if got := introspector.count(); got != 2 {
+		t.Fatalf("introspection requests after exp = %d, want 2", got)
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"crypto/aes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/cipher"
+	"encoding/json"
+	"encoding/base64"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// jweHeader is the protected header of a compact jwe (RFC 7516)
+type jweHeader struct {
+	Alg		string	`json:"alg"`
+	Enc		string	`json:"enc"`
+	Zip		string	`json:"zip,omitempty"`
+	Cty		string	`json:"cty,omitempty"`
+	Kid		string	`json:"kid,omitempty"`
+}
+
+// About check if the token is a compact jwe (5 parts separated by dot)
+func isJwe(bearerToken string) bool {
+	return strings.Count(bearerToken, ".") == 4
+}
+
+// About decrypt a nested jwe and return the inner jws, a jws is returned as is (unless the encryption is required)
+// The inner jws goes through the normal signature verification
+func (w *WorkerService) TokenDecryption(ctx context.Context, bearerToken string) (string, error) {
+	if !isJwe(bearerToken) {
+		if w.appServer.TokenEncryption != nil && w.appServer.TokenEncryption.Required {
+			w.logger.Warn().
+				Ctx(ctx).
+				Msg("token NOT ENCRYPTED")
+			return "", erro.ErrTokenNotEncrypted
+		}
+		return bearerToken, nil
+	}
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","TokenDecryption").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.TokenDecryption", trace.SpanKindServer)
+	defer span.End()
+
+	if w.appServer.RsaKey == nil || w.appServer.RsaKey.RsaPrivate == nil {
+		return "", erro.ErrTokenDecrypt
+	}
+
+	innerToken, err := decryptJwe(bearerToken, w.appServer.RsaKey.RsaPrivate)
+	if err != nil {
+		w.logger.Warn().
+			Ctx(ctx).
+			Err(err).
+			Msg("token DECRYPTION failed")
+		return "", err
+	}
+
+	// only a nested jwt is accepted, the payload must be a signed jws
+	if strings.Count(innerToken, ".") != 2 {
+		return "", erro.ErrTokenMalformed
+	}
+
+	return innerToken, nil
+}
+
+// About decrypt a compact jwe with RSA-OAEP-256 (cek) and A256GCM (content)
+func decryptJwe(bearerToken string, privateKey *rsa.PrivateKey) (string, error) {
+	parts := strings.Split(bearerToken, ".")
+	if len(parts) != 5 {
+		return "", erro.ErrTokenMalformed
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", erro.ErrTokenMalformed
+	}
+	header := jweHeader{}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return "", erro.ErrTokenMalformed
+	}
+
+	if header.Alg != "RSA-OAEP-256" || header.Enc != "A256GCM" {
+		return "", erro.ErrAlgorithmNotAllowed
+	}
+	// compressed payload is not supported (decompression bomb)
+	if header.Zip != "" {
+		return "", erro.ErrTokenDecrypt
+	}
+	if header.Cty != "" && !strings.EqualFold(header.Cty, "JWT") {
+		return "", erro.ErrTokenMalformed
+	}
+
+	encryptedKey, err1 := base64.RawURLEncoding.DecodeString(parts[1])
+	iv, err2 := base64.RawURLEncoding.DecodeString(parts[2])
+	ciphertext, err3 := base64.RawURLEncoding.DecodeString(parts[3])
+	tag, err4 := base64.RawURLEncoding.DecodeString(parts[4])
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return "", erro.ErrTokenMalformed
+	}
+
+	cek, err := rsa.DecryptOAEP(sha256.New(), nil, privateKey, encryptedKey, nil)
+	if err != nil || len(cek) != 32 {
+		return "", erro.ErrTokenDecrypt
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return "", erro.ErrTokenDecrypt
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil || len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+		return "", erro.ErrTokenDecrypt
+	}
+
+	// the additional authenticated data is the encoded protected header
+	plaintext, err := gcm.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return "", erro.ErrTokenDecrypt
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"context"
+	"testing"
+	"strings"
+	"crypto/aes"
+	"crypto/rsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/cipher"
+	"encoding/json"
+	"encoding/base64"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// encryptTestJwe creates a compact jwe with RSA-OAEP-256 (cek) and A256GCM (content)
+func encryptTestJwe(t *testing.T, publicKey *rsa.PublicKey, header jweHeader, payload string) string {
+	t.Helper()
+
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(rawHeader)
+
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		t.Fatal(err)
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, cek, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := gcm.Seal(nil, iv, []byte(payload), []byte(encodedHeader))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		encodedHeader,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, ".")
+}
+
+func TestTokenDecryption(t *testing.T) {
+	key := newTestRsaKey(t)
+	otherKey := newTestRsaKey(t)
+
+	jws := signTestToken(t, jwt.SigningMethodRS256, key, "", testClaims())
+	header := jweHeader{Alg: "RSA-OAEP-256", Enc: "A256GCM", Cty: "JWT"}
+
+	// a jwe with the content changed fails the gcm authentication
+	tampered := strings.Split(encryptTestJwe(t, &key.PublicKey, header, jws), ".")
+	tampered[3] = base64.RawURLEncoding.EncodeToString([]byte("tampered"))
+
+	tests := []struct {
+		name		string
+		token		string
+		required	bool
+		noKey		bool
+		want		string
+		wantErr		error
+	}{
+		{name: "jwe", token: encryptTestJwe(t, &key.PublicKey, header, jws), want: jws},
+		{name: "jwe required", token: encryptTestJwe(t, &key.PublicKey, header, jws), required: true, want: jws},
+		{name: "jwe without cty", token: encryptTestJwe(t, &key.PublicKey, jweHeader{Alg: "RSA-OAEP-256", Enc: "A256GCM"}, jws), want: jws},
+		{name: "jws not required", token: jws, want: jws},
+		{name: "jws required", token: jws, required: true, wantErr: erro.ErrTokenNotEncrypted},
+		{name: "alg not allowed", token: encryptTestJwe(t, &key.PublicKey, jweHeader{Alg: "RSA1_5", Enc: "A256GCM"}, jws), wantErr: erro.ErrAlgorithmNotAllowed},
+		{name: "enc not allowed", token: encryptTestJwe(t, &key.PublicKey, jweHeader{Alg: "RSA-OAEP-256", Enc: "A128CBC-HS256"}, jws), wantErr: erro.ErrAlgorithmNotAllowed},
+		{name: "compressed", token: encryptTestJwe(t, &key.PublicKey, jweHeader{Alg: "RSA-OAEP-256", Enc: "A256GCM", Zip: "DEF"}, jws), wantErr: erro.ErrTokenDecrypt},
+		{name: "cty not jwt", token: encryptTestJwe(t, &key.PublicKey, jweHeader{Alg: "RSA-OAEP-256", Enc: "A256GCM", Cty: "json"}, jws), wantErr: erro.ErrTokenMalformed},
+		{name: "other key", token: encryptTestJwe(t, &otherKey.PublicKey, header, jws), wantErr: erro.ErrTokenDecrypt},
+		{name: "tampered", token: strings.Join(tampered, "."), wantErr: erro.ErrTokenDecrypt},
+		{name: "payload not a jws", token: encryptTestJwe(t, &key.PublicKey, header, `{"username":"user-01"}`), wantErr: erro.ErrTokenMalformed},
+		{name: "malformed", token: "a.b.c.d.e", wantErr: erro.ErrTokenMalformed},
+		{name: "without private key", token: encryptTestJwe(t, &key.PublicKey, header, jws), noKey: true, wantErr: erro.ErrTokenDecrypt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsaKey := &model.RsaKey{RsaPrivate: key}
+			if tt.noKey {
+				rsaKey = nil
+			}
+			w := newTestWorkerService(t, &model.AppServer{
+				RsaKey: rsaKey,
+				TokenEncryption: &model.TokenEncryption{Required: tt.required},
+			})
+
+			got, err := w.TokenDecryption(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenDecryption() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("TokenDecryption() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
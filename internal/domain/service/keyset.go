@@ -0,0 +1,240 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"math/big"
+	"crypto/rsa"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/ed25519"
+	"encoding/base64"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// KeySet holds the public keys used to check the token signature, indexed by kid
+// The static keys (added at startup) are kept when the remote jwks is reloaded
+type KeySet struct {
+	mutex		sync.RWMutex
+	keys		map[string]interface{}
+	static		map[string]interface{}
+	defaultKid	string
+}
+
+// About create a empty key set
+func NewKeySet(defaultKid string) *KeySet {
+	return &KeySet{
+		keys: make(map[string]interface{}),
+		static: make(map[string]interface{}),
+		defaultKid: defaultKid,
+	}
+}
+
+// About add (or replace) a static key in the key set
+func (k *KeySet) Add(kid string, key interface{}) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.keys[kid] = key
+	k.static[kid] = key
+}
+
+// About get the key by kid
+// When the kid is not informed, the default kid is used
+func (k *KeySet) Get(kid string) (interface{}, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	if kid == "" {
+		kid = k.defaultKid
+	}
+
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+
+	// a single key without kid (ex: a plain HS256 secret) is used for any kid
+	if key, ok := k.keys[""]; ok && len(k.keys) == 1 {
+		return key, nil
+	}
+
+	// when the key loaded at startup is the only key, it is used for any kid (tokens issued with other kid name)
+	if len(k.keys) == 1 && len(k.static) == 1 {
+		for staticKid, key := range k.static {
+			if _, ok := k.keys[staticKid]; ok {
+				return key, nil
+			}
+		}
+	}
+
+	return nil, erro.ErrKidNotFound
+}
+
+// About replace all keys of the key set (reload)
+func (k *KeySet) Replace(keys map[string]interface{}, defaultKid string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.keys = keys
+	k.defaultKid = defaultKid
+}
+
+// About check if the kid is explicitly in the key set
+func (k *KeySet) Has(kid string) bool {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	_, ok := k.keys[kid]
+	return ok
+}
+
+// About the number of keys loaded
+func (k *KeySet) Len() int {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return len(k.keys)
+}
+
+// About load the keys of a key type (kty RSA, EC, OKP) from a jwks document in the key set
+func (k *KeySet) LoadJwks(jwks *model.Jwks, kty string, logger *zerolog.Logger) error {
+	logger.Info().
+		Str("func","LoadJwks").
+		Str("kty", kty).Send()
+
+	for _, jwtKeyInfo := range jwks.JwtKeyInfo {
+		if jwtKeyInfo.Type != kty {
+			continue
+		}
+		if jwtKeyInfo.Use != "" && jwtKeyInfo.Use != "sig" {
+			logger.Warn().
+				Str("kid", jwtKeyInfo.Kid).
+				Msgf("jwk use %s ignored", jwtKeyInfo.Use)
+			continue
+		}
+
+		key, err := parseJwkToPublicKey(jwtKeyInfo)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("kid", jwtKeyInfo.Kid).
+				Msg("erro parse jwk")
+			return err
+		}
+
+		k.Add(jwtKeyInfo.Kid, key)
+	}
+
+	return nil
+}
+
+// About reload the remote keys of a key type (kty RSA, EC, OKP), the static keys are kept
+// An invalid jwk is ignored, the others are loaded
+func (k *KeySet) ReloadJwks(jwks *model.Jwks, kty string, logger *zerolog.Logger) {
+	keys := make(map[string]interface{})
+
+	for _, jwtKeyInfo := range jwks.JwtKeyInfo {
+		if jwtKeyInfo.Type != kty || (jwtKeyInfo.Use != "" && jwtKeyInfo.Use != "sig") {
+			continue
+		}
+
+		key, err := parseJwkToPublicKey(jwtKeyInfo)
+		if err != nil {
+			logger.Warn().
+				Err(err).
+				Str("kid", jwtKeyInfo.Kid).
+				Msg("jwk ignored")
+			continue
+		}
+		keys[jwtKeyInfo.Kid] = key
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	for kid, key := range k.static {
+		keys[kid] = key
+	}
+	k.keys = keys
+}
+
+// About convert a jwk in a crypto public key
+func parseJwkToPublicKey(jwtKeyInfo model.JwtKeyInfo) (interface{}, error) {
+	switch jwtKeyInfo.Type {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwtKeyInfo.NBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: modulus: %v", erro.ErrDecodeKey, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwtKeyInfo.EBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: exponent: %v", erro.ErrDecodeKey, err)
+		}
+		if len(n) == 0 || len(e) == 0 {
+			return nil, fmt.Errorf("%w: modulus or exponent missing", erro.ErrDecodeKey)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch jwtKeyInfo.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("%w: crv %s not supported", erro.ErrDecodeKey, jwtKeyInfo.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwtKeyInfo.XBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: x: %v", erro.ErrDecodeKey, err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwtKeyInfo.YBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: y: %v", erro.ErrDecodeKey, err)
+		}
+
+		// check the point is on the curve using the uncompressed form
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("%w: invalid ec point size", erro.ErrDecodeKey)
+		}
+		point := append([]byte{4}, append(x, y...)...)
+		if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("%w: %v", erro.ErrDecodeKey, err)
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X: new(big.Int).SetBytes(x),
+			Y: new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		if jwtKeyInfo.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: crv %s not supported", erro.ErrDecodeKey, jwtKeyInfo.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwtKeyInfo.XBase64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: x: %v", erro.ErrDecodeKey, err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid ed25519 key size", erro.ErrDecodeKey)
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: kty %s not supported", erro.ErrDecodeKey, jwtKeyInfo.Type)
+	}
+}
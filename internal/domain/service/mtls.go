@@ -0,0 +1,166 @@
+package service
+
+import (
+	"time"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/shared/certificate"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// About check the certificate-bound token (RFC 8705), the cnf x5t#S256 must be the thumbprint of the mTLS client certificate
+// A stolen token can not be used by another client
+func (w *WorkerService) CertificateBindingValidation(ctx context.Context, claims model.JwtData, certX509 *x509.Certificate) error {
+	certBoundRequired := w.appServer.ClientCertValidation != nil && w.appServer.ClientCertValidation.CertBoundRequired
+	if (claims.Cnf == nil || claims.Cnf.X5tS256 == "") && !certBoundRequired {
+		return nil
+	}
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","CertificateBindingValidation").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.CertificateBindingValidation", trace.SpanKindServer)
+	defer span.End()
+
+	if claims.Cnf == nil || claims.Cnf.X5tS256 == "" {
+		w.logger.Warn().
+			Ctx(ctx).
+			Msg("token cnf x5t#S256 MISSING")
+		return erro.ErrCertBinding
+	}
+
+	if certX509 == nil {
+		w.logger.Warn().
+			Ctx(ctx).
+			Msg("token certificate-bound but the client certificate was not informed")
+		return erro.ErrCertBinding
+	}
+
+	thumbprint := sha256.Sum256(certX509.Raw)
+	x5tS256 := base64.RawURLEncoding.EncodeToString(thumbprint[:])
+
+	if subtle.ConstantTimeCompare([]byte(x5tS256), []byte(claims.Cnf.X5tS256)) != 1 {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("cnf_x5t#S256", claims.Cnf.X5tS256).
+			Str("client_cert_x5t#S256", x5tS256).
+			Msg("token certificate binding MISMATCH")
+		return erro.ErrCertBinding
+	}
+
+	return nil
+}
+
+// clientCertTrust is the trust bundle (RsaKey.CaCert) of the mTLS client certificates
+// The self-signed certificates are the roots, the others are intermediates
+type clientCertTrust struct {
+	roots			*x509.CertPool
+	intermediates	*x509.CertPool
+}
+
+// About load the trust bundle used in the client certificate chain validation
+func (w *WorkerService) SetClientCertTrust(ctx context.Context) error {
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","SetClientCertTrust").Send()
+
+	if w.appServer.RsaKey == nil || w.appServer.RsaKey.CaCert == "" {
+		return erro.ErrCertChain
+	}
+
+	caCerts, err := certificate.ParsePemToCertx509List(&w.appServer.RsaKey.CaCert, w.logger)
+	if err != nil {
+		return err
+	}
+
+	trust := &clientCertTrust{
+		roots: x509.NewCertPool(),
+		intermediates: x509.NewCertPool(),
+	}
+	for _, caCert := range caCerts {
+		if bytes.Equal(caCert.RawSubject, caCert.RawIssuer) && caCert.CheckSignatureFrom(caCert) == nil {
+			trust.roots.AddCert(caCert)
+		} else {
+			trust.intermediates.AddCert(caCert)
+		}
+	}
+	w.clientCertTrust = trust
+
+	return nil
+}
+
+// About validate the mTLS client certificate chain to the trust bundle
+// The validity period, key usage, extended key usage (client auth) and the name constraints of the chain are checked
+func (w *WorkerService) ClientCertChainValidation(ctx context.Context, certX509 *x509.Certificate) (*model.ClientCertInfo, error) {
+	if w.clientCertTrust == nil {
+		return nil, nil
+	}
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","ClientCertChainValidation").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.ClientCertChainValidation", trace.SpanKindServer)
+	defer span.End()
+
+	if certX509 == nil {
+		w.logger.Warn().
+			Ctx(ctx).
+			Msg("client certificate NOT INFORMED")
+		return nil, erro.ErrCertChain
+	}
+
+	// the name constraints of the chain are checked by the verify
+	if _, err := certX509.Verify(x509.VerifyOptions{
+		Roots: w.clientCertTrust.roots,
+		Intermediates: w.clientCertTrust.intermediates,
+		CurrentTime: time.Now(),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		w.logger.Warn().
+			Ctx(ctx).
+			Err(err).
+			Str("subject", certX509.Subject.String()).
+			Msg("client certificate chain INVALID")
+		return nil, erro.ErrCertChain
+	}
+
+	// when the key usage is informed the client certificate must sign (tls handshake)
+	if certX509.KeyUsage != 0 && certX509.KeyUsage & x509.KeyUsageDigitalSignature == 0 {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("subject", certX509.Subject.String()).
+			Msg("client certificate key usage without digital signature")
+		return nil, erro.ErrCertChain
+	}
+
+	clientCertInfo := &model.ClientCertInfo{
+		SubjectDN: certX509.Subject.String(),
+		IssuerDN: certX509.Issuer.String(),
+		SerialNumber: certX509.SerialNumber.Text(16),
+		DnsNames: certX509.DNSNames,
+		EmailAddresses: certX509.EmailAddresses,
+	}
+	for _, ip := range certX509.IPAddresses {
+		clientCertInfo.IpAddresses = append(clientCertInfo.IpAddresses, ip.String())
+	}
+	for _, uri := range certX509.URIs {
+		clientCertInfo.Uris = append(clientCertInfo.Uris, uri.String())
+	}
+	if spiffeId, err := spiffeIdFromCert(certX509); err == nil {
+		clientCertInfo.SpiffeId = spiffeId
+	}
+
+	return clientCertInfo, nil
+}
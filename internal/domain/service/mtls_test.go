@@ -0,0 +1,175 @@
+package service
+
+import (
+	"time"
+	"errors"
+	"context"
+	"testing"
+	"math/big"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/elliptic"
+	"encoding/base64"
+	"crypto/x509/pkix"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+func TestCertificateBindingValidation(t *testing.T) {
+	ca := newTestCa(t, "ca-01")
+	cert := ca.issue(t, 10, testOcspUrl)
+	otherCert := ca.issue(t, 11, testOcspUrl)
+
+	thumbprint := sha256.Sum256(cert.Raw)
+	x5tS256 := base64.RawURLEncoding.EncodeToString(thumbprint[:])
+
+	tests := []struct {
+		name		string
+		cnf			*model.Confirmation
+		certBound	bool // CERT_BOUND_TOKEN_REQUIRED
+		cert		*x509.Certificate
+		wantErr		error
+	}{
+		{name: "matching thumbprint", cnf: &model.Confirmation{X5tS256: x5tS256}, cert: cert},
+		{name: "matching thumbprint required", cnf: &model.Confirmation{X5tS256: x5tS256}, certBound: true, cert: cert},
+		{name: "mismatched thumbprint", cnf: &model.Confirmation{X5tS256: x5tS256}, cert: otherCert, wantErr: erro.ErrCertBinding},
+		{name: "missing certificate", cnf: &model.Confirmation{X5tS256: x5tS256}, wantErr: erro.ErrCertBinding},
+		{name: "thumbprint not base64url", cnf: &model.Confirmation{X5tS256: base64.StdEncoding.EncodeToString(thumbprint[:])}, cert: cert, wantErr: erro.ErrCertBinding},
+		{name: "not bound"},
+		{name: "not bound required", certBound: true, cert: cert, wantErr: erro.ErrCertBinding},
+		{name: "empty cnf required", cnf: &model.Confirmation{}, certBound: true, cert: cert, wantErr: erro.ErrCertBinding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorkerService(t, &model.AppServer{
+				ClientCertValidation: &model.ClientCertValidation{CertBoundRequired: tt.certBound},
+			})
+
+			err := w.CertificateBindingValidation(context.Background(), model.JwtData{Cnf: tt.cnf}, tt.cert)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CertificateBindingValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// issueTemplate signs the template with the CA, a CA template returns the issued CA
+func (c *testCa) issueTemplate(t *testing.T, template *x509.Certificate) *testCa {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.cert, &key.PublicKey, c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCa{cert: cert, key: key}
+}
+
+// clientTemplate is a valid client certificate template
+func clientTemplate(serial int64, dnsName string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject: pkix.Name{CommonName: "client-01"},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		DNSNames: []string{dnsName},
+	}
+}
+
+func TestClientCertChainValidation(t *testing.T) {
+	root := newTestCa(t, "root-01")
+	otherRoot := newTestCa(t, "root-02")
+
+	// the intermediate only issues names of example.com
+	intermediate := root.issueTemplate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{CommonName: "intermediate-01"},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		IsCA: true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign,
+		PermittedDNSDomains: []string{"example.com"},
+	})
+
+	expired := clientTemplate(13, "client.example.com")
+	expired.NotAfter = time.Now().Add(-time.Minute)
+
+	serverAuth := clientTemplate(14, "client.example.com")
+	serverAuth.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+
+	keyEncipherment := clientTemplate(15, "client.example.com")
+	keyEncipherment.KeyUsage = x509.KeyUsageKeyEncipherment
+
+	tests := []struct {
+		name		string
+		cert		*x509.Certificate
+		wantErr		error
+	}{
+		{name: "issued by the root", cert: root.issueTemplate(t, clientTemplate(10, "client.example.org")).cert},
+		{name: "issued by the intermediate", cert: intermediate.issueTemplate(t, clientTemplate(11, "client.example.com")).cert},
+		{name: "name constraint violated", cert: intermediate.issueTemplate(t, clientTemplate(12, "client.example.org")).cert, wantErr: erro.ErrCertChain},
+		{name: "expired", cert: intermediate.issueTemplate(t, expired).cert, wantErr: erro.ErrCertChain},
+		{name: "server auth only", cert: intermediate.issueTemplate(t, serverAuth).cert, wantErr: erro.ErrCertChain},
+		{name: "key usage without digital signature", cert: intermediate.issueTemplate(t, keyEncipherment).cert, wantErr: erro.ErrCertChain},
+		{name: "unknown root", cert: otherRoot.issueTemplate(t, clientTemplate(16, "client.example.com")).cert, wantErr: erro.ErrCertChain},
+		{name: "no certificate", wantErr: erro.ErrCertChain},
+	}
+
+	w := newTestWorkerService(t, &model.AppServer{
+		RsaKey: &model.RsaKey{CaCert: root.pem() + intermediate.pem()},
+	})
+	if err := w.SetClientCertTrust(context.Background()); err != nil {
+		t.Fatalf("SetClientCertTrust() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientCertInfo, err := w.ClientCertChainValidation(context.Background(), tt.cert)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClientCertChainValidation() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if clientCertInfo.SubjectDN != tt.cert.Subject.String() || clientCertInfo.IssuerDN != tt.cert.Issuer.String() {
+				t.Fatalf("subject = %q, issuer = %q, want %q, %q", clientCertInfo.SubjectDN, clientCertInfo.IssuerDN, tt.cert.Subject.String(), tt.cert.Issuer.String())
+			}
+			if clientCertInfo.SerialNumber != tt.cert.SerialNumber.Text(16) {
+				t.Fatalf("serial number = %q, want %q", clientCertInfo.SerialNumber, tt.cert.SerialNumber.Text(16))
+			}
+		})
+	}
+}
+
+func TestClientCertChainValidationDisabled(t *testing.T) {
+	ca := newTestCa(t, "ca-01")
+	w := newTestWorkerService(t, &model.AppServer{})
+
+	// without the trust bundle the chain is not checked
+	clientCertInfo, err := w.ClientCertChainValidation(context.Background(), ca.issue(t, 10, testOcspUrl))
+	if err != nil || clientCertInfo != nil {
+		t.Fatalf("ClientCertChainValidation() = %v, %v, want nil, nil", clientCertInfo, err)
+	}
+}
+
+func TestSetClientCertTrustWithoutCaCert(t *testing.T) {
+	w := newTestWorkerService(t, &model.AppServer{})
+
+	if err := w.SetClientCertTrust(context.Background()); !errors.Is(err, erro.ErrCertChain) {
+		t.Fatalf("SetClientCertTrust() error = %v, want %v", err, erro.ErrCertChain)
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"time"
+	"bytes"
+	"context"
+	"crypto/x509"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/shared/cache"
+	"github.com/lambda-go-oauth2/shared/certificate"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OcspResponder sends a der ocsp request to the responder url and returns the der response
+type OcspResponder interface {
+	Query(ctx context.Context, responderUrl string, ocspRequest []byte) ([]byte, error)
+}
+
+// ocspCheck is the ocsp responder, the issuers (RsaKey.CaCert) and the cache of the status by serial
+type ocspCheck struct {
+	ocspResponder	OcspResponder
+	caCerts			[]*x509.Certificate
+	cache			*cache.Cache[int]
+}
+
+// About set the ocsp responder, the request and the response are signed by the issuer in the RsaKey.CaCert
+func (w *WorkerService) SetOcspResponder(ctx context.Context, ocspResponder OcspResponder) error {
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","SetOcspResponder").Send()
+
+	if w.appServer.RsaKey == nil || w.appServer.RsaKey.CaCert == "" {
+		return erro.ErrOcspCheck
+	}
+
+	caCerts, err := certificate.ParsePemToCertx509List(&w.appServer.RsaKey.CaCert, w.logger)
+	if err != nil {
+		return err
+	}
+
+	cacheSize := 0
+	if w.appServer.ClientCertValidation != nil {
+		cacheSize = w.appServer.ClientCertValidation.OcspCacheSize
+	}
+
+	w.ocspCheck = &ocspCheck{
+		ocspResponder: ocspResponder,
+		caCerts: caCerts,
+		cache: cache.NewCache[int](cacheSize),
+	}
+
+	return nil
+}
+
+// About check the mTLS client certificate status in the ocsp responder of its AIA
+// The status is cached until the response NextUpdate, only a unreachable responder is handled by the fail open/closed policy
+// A certificate of a unknown issuer or a ocsp Unknown status is denied
+func (w *WorkerService) ClientCertOcspValidation(ctx context.Context, certX509 *x509.Certificate) error {
+	if w.ocspCheck == nil || certX509 == nil {
+		return nil
+	}
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","ClientCertOcspValidation").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.ClientCertOcspValidation", trace.SpanKindServer)
+	defer span.End()
+
+	// without AIA ocsp there is nothing to check (the crl may cover it)
+	if len(certX509.OCSPServer) == 0 {
+		w.logger.Debug().
+			Ctx(ctx).
+			Str("subject", certX509.Subject.String()).
+			Msg("client certificate without ocsp responder")
+		return nil
+	}
+
+	var issuer *x509.Certificate
+	for _, caCert := range w.ocspCheck.caCerts {
+		if bytes.Equal(caCert.RawSubject, certX509.RawIssuer) {
+			issuer = caCert
+			break
+		}
+	}
+	if issuer == nil {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("issuer", certX509.Issuer.String()).
+			Str("subject", certX509.Subject.String()).
+			Msg("client certificate issuer UNKNOWN (ocsp)")
+		return erro.ErrOcspCheck
+	}
+
+	cacheKey := string(certX509.RawIssuer) + certX509.SerialNumber.Text(16)
+	status, ok := w.ocspCheck.cache.Get(cacheKey)
+	if !ok {
+		// a fail open is not cached, the responder is queried again in the next request
+		var nextUpdate time.Time
+		var err error
+		status, nextUpdate, err = w.ocspQuery(ctx, certX509, issuer)
+		if err != nil {
+			return w.ocspFailure(ctx, err)
+		}
+
+		ttl := time.Until(nextUpdate)
+		if nextUpdate.IsZero() && w.appServer.ClientCertValidation != nil {
+			ttl = w.appServer.ClientCertValidation.OcspCacheTTL
+		}
+		w.ocspCheck.cache.Set(cacheKey, status, ttl)
+	}
+
+	switch status {
+	case ocsp.Good:
+		return nil
+	case ocsp.Revoked:
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("serial_number", certX509.SerialNumber.Text(16)).
+			Str("subject", certX509.Subject.String()).
+			Msg("client certificate REVOKED (ocsp)")
+		return erro.ErrCertRevoked
+	default:
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("serial_number", certX509.SerialNumber.Text(16)).
+			Str("subject", certX509.Subject.String()).
+			Msg("client certificate status UNKNOWN (ocsp)")
+		return erro.ErrOcspCheck
+	}
+}
+
+// About query the ocsp responders of the certificate AIA, the response must be signed by the issuer (or its delegated responder)
+func (w *WorkerService) ocspQuery(ctx context.Context, certX509 *x509.Certificate, issuer *x509.Certificate) (int, time.Time, error) {
+	ocspRequest, err := ocsp.CreateRequest(certX509, issuer, nil)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	for _, responderUrl := range certX509.OCSPServer {
+		raw, err := w.ocspCheck.ocspResponder.Query(ctx, responderUrl, ocspRequest)
+		if err != nil {
+			w.logger.Error().
+				Ctx(ctx).
+				Err(err).
+				Str("responder_url", responderUrl).
+				Msg("erro query ocsp responder")
+			continue
+		}
+
+		ocspResponse, err := ocsp.ParseResponseForCert(raw, certX509, issuer)
+		if err != nil {
+			w.logger.Error().
+				Ctx(ctx).
+				Err(err).
+				Str("responder_url", responderUrl).
+				Msg("erro ocsp response INVALID")
+			continue
+		}
+
+		// a response already expired is not accepted
+		if !ocspResponse.NextUpdate.IsZero() && time.Now().After(ocspResponse.NextUpdate) {
+			continue
+		}
+
+		return ocspResponse.Status, ocspResponse.NextUpdate, nil
+	}
+
+	return 0, time.Time{}, erro.ErrOcspCheck
+}
+
+// About apply the fail open/closed policy when no ocsp responder could be reached
+func (w *WorkerService) ocspFailure(ctx context.Context, err error) error {
+	if w.appServer.ClientCertValidation != nil && w.appServer.ClientCertValidation.OcspFailOpen {
+		w.logger.Warn().
+			Ctx(ctx).
+			Err(err).
+			Msg("ocsp responder unreachable, FAIL OPEN")
+		return nil
+	}
+
+	w.logger.Warn().
+		Ctx(ctx).
+		Err(err).
+		Msg("ocsp responder unreachable, FAIL CLOSED")
+	return erro.ErrOcspCheck
+}
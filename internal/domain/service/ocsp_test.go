@@ -0,0 +1,242 @@
+package service
+
+import (
+	"sync"
+	"time"
+	"errors"
+	"context"
+	"testing"
+	"math/big"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/pem"
+	"crypto/x509/pkix"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// the responder urls informed in the AIA of the client certificates
+const (
+	testOcspUrl			= "http://ocsp.test"
+	testOcspClosedUrl	= "http://ocsp-closed.test"
+)
+
+// testCa is a CA that issues the client certificates
+type testCa struct {
+	cert	*x509.Certificate
+	key		*ecdsa.PrivateKey
+}
+
+func newTestCa(t *testing.T, name string) *testCa {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{CommonName: name},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		IsCA: true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCa{cert: cert, key: key}
+}
+
+func (c *testCa) pem() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}))
+}
+
+// issue a client certificate with the ocsp responder url in its AIA
+func (c *testCa) issue(t *testing.T, serial int64, responderUrl string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject: pkix.Name{CommonName: "client-01"},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		OCSPServer: []string{responderUrl},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.cert, &key.PublicKey, c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// testOcspResponder is a ocsp responder stand-in, the status is by serial and it can be taken down
+type testOcspResponder struct {
+	mutex		sync.Mutex
+	ca			*testCa
+	status		map[int64]int
+	down		bool
+	queries		int
+}
+
+func newTestOcspResponder(ca *testCa) *testOcspResponder {
+	return &testOcspResponder{
+		ca: ca,
+		status: make(map[int64]int),
+	}
+}
+
+// Query answers the requests to testOcspUrl, any other url is unreachable
+func (r *testOcspResponder) Query(ctx context.Context, responderUrl string, ocspRequest []byte) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if responderUrl != testOcspUrl {
+		return nil, errors.New("ocsp responder unreachable")
+	}
+	r.queries++
+	if r.down {
+		return nil, errors.New("ocsp responder unavailable")
+	}
+
+	request, err := ocsp.ParseRequest(ocspRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	template := ocsp.Response{
+		Status: r.status[request.SerialNumber.Int64()],
+		SerialNumber: request.SerialNumber,
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	if template.Status == ocsp.Revoked {
+		template.RevokedAt = time.Now().Add(-time.Minute)
+	}
+
+	return ocsp.CreateResponse(r.ca.cert, r.ca.cert, template, r.ca.key)
+}
+
+func (r *testOcspResponder) set(serial int64, status int, down bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.status[serial] = status
+	r.down = down
+}
+
+func (r *testOcspResponder) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.queries
+}
+
+func newOcspWorkerService(t *testing.T, ca *testCa, responder *testOcspResponder, failOpen bool) *WorkerService {
+	t.Helper()
+
+	w := newTestWorkerService(t, &model.AppServer{
+		RsaKey: &model.RsaKey{CaCert: ca.pem()},
+		ClientCertValidation: &model.ClientCertValidation{
+			OcspEnabled: true,
+			OcspFailOpen: failOpen,
+			OcspCacheTTL: time.Minute,
+			OcspCacheSize: 10,
+		},
+	})
+
+	if err := w.SetOcspResponder(context.Background(), responder); err != nil {
+		t.Fatalf("SetOcspResponder() error = %v", err)
+	}
+	return w
+}
+
+func TestClientCertOcspValidation(t *testing.T) {
+	ca := newTestCa(t, "ca-01")
+	otherCa := newTestCa(t, "ca-02")
+	responder := newTestOcspResponder(ca)
+
+	tests := []struct {
+		name		string
+		cert		*x509.Certificate
+		serial		int64
+		status		int
+		failOpen	bool
+		wantErr		error
+	}{
+		{name: "good", cert: ca.issue(t, 10, testOcspUrl), serial: 10, status: ocsp.Good},
+		{name: "revoked", cert: ca.issue(t, 11, testOcspUrl), serial: 11, status: ocsp.Revoked, wantErr: erro.ErrCertRevoked},
+		{name: "revoked fail open", cert: ca.issue(t, 12, testOcspUrl), serial: 12, status: ocsp.Revoked, failOpen: true, wantErr: erro.ErrCertRevoked},
+		{name: "unknown status", cert: ca.issue(t, 13, testOcspUrl), serial: 13, status: ocsp.Unknown, wantErr: erro.ErrOcspCheck},
+		{name: "unknown status fail open", cert: ca.issue(t, 14, testOcspUrl), serial: 14, status: ocsp.Unknown, failOpen: true, wantErr: erro.ErrOcspCheck},
+		{name: "unknown issuer fail open", cert: otherCa.issue(t, 15, testOcspUrl), serial: 15, status: ocsp.Good, failOpen: true, wantErr: erro.ErrOcspCheck},
+		{name: "unreachable fail closed", cert: ca.issue(t, 16, testOcspClosedUrl), wantErr: erro.ErrOcspCheck},
+		{name: "unreachable fail open", cert: ca.issue(t, 17, testOcspClosedUrl), failOpen: true},
+		{name: "no certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responder.set(tt.serial, tt.status, false)
+
+			w := newOcspWorkerService(t, ca, responder, tt.failOpen)
+			err := w.ClientCertOcspValidation(context.Background(), tt.cert)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClientCertOcspValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientCertOcspValidationCache(t *testing.T) {
+	ca := newTestCa(t, "ca-01")
+	responder := newTestOcspResponder(ca)
+	w := newOcspWorkerService(t, ca, responder, true)
+
+	cert := ca.issue(t, 20, testOcspUrl)
+
+	tests := []struct {
+		name		string
+		status		int
+		down		bool
+		wantErr		error
+		wantQueries	int
+	}{
+		{name: "responder down fail open", down: true, wantQueries: 1},
+		{name: "fail open not cached", status: ocsp.Revoked, wantErr: erro.ErrCertRevoked, wantQueries: 2},
+		{name: "revoked cached", status: ocsp.Good, wantErr: erro.ErrCertRevoked, wantQueries: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responder.set(20, tt.status, tt.down)
+
+			err := w.ClientCertOcspValidation(context.Background(), cert)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClientCertOcspValidation() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := responder.count(); got != tt.wantQueries {
+				t.Fatalf("ocsp queries = %d, want %d", got, tt.wantQueries)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+)
+
+// pathParamName is the name of a template parameter, ex: {id}
+var pathParamName = regexp.MustCompile(`^\{[A-Za-z0-9_]+\}$`)
+
+// pathTemplate is a route path split by segment
+// literal (account), parameter ({id}) or * (one segment) and ** (zero or more segments, only the last one)
+type pathTemplate []string
+
+// segment ranks of the route precedence, the lower is the more specific
+const (
+	segmentLiteral = iota
+	segmentParam
+	segmentAny
+)
+
+// About parse and validate a path template
+func parsePathTemplate(path string) (pathTemplate, error) {
+	segments := splitPath(path)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("%w: path template empty", erro.ErrBadRequest)
+	}
+
+	for i, segment := range segments {
+		switch {
+		case segment == "":
+			return nil, fmt.Errorf("%w: path template %s with a empty segment", erro.ErrBadRequest, path)
+		case segment == "**":
+			if i != len(segments)-1 {
+				return nil, fmt.Errorf("%w: path template %s with ** not in the last segment", erro.ErrBadRequest, path)
+			}
+		case segment == "*", pathParamName.MatchString(segment):
+		case strings.ContainsAny(segment, "{}*"):
+			return nil, fmt.Errorf("%w: path template %s segment %s invalid", erro.ErrBadRequest, path, segment)
+		}
+	}
+
+	return pathTemplate(segments), nil
+}
+
+// About split the path by segment, without the leading and trailing /
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// About the rank of a segment
+func segmentRank(segment string) int {
+	switch {
+	case segment == "**":
+		return segmentAny
+	case segment == "*", pathParamName.MatchString(segment):
+		return segmentParam
+	default:
+		return segmentLiteral
+	}
+}
+
+// About the template with the parameters as *, two templates with the same key match the same paths
+func (t pathTemplate) key() string {
+	segments := make([]string, len(t))
+	for i, segment := range t {
+		if segmentRank(segment) == segmentParam {
+			segment = "*"
+		}
+		segments[i] = segment
+	}
+	return strings.Join(segments, "/")
+}
+
+// About check if the path segments match the template, a segment is never matched partially
+func (t pathTemplate) match(segments []string) bool {
+	for i, segment := range t {
+		if segment == "**" {
+			return true
+		}
+		if i >= len(segments) || segments[i] == "" {
+			return false
+		}
+		if segmentRank(segment) == segmentLiteral && segment != segments[i] {
+			return false
+		}
+	}
+	return len(t) == len(segments)
+}
+
+// About the route precedence, segment by segment literal < parameter (or *) < **
+// With the same segments the longer template is more specific, unless the next segment is **
+func comparePathTemplates(a, b pathTemplate) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if rankA, rankB := segmentRank(a[i]), segmentRank(b[i]); rankA != rankB {
+			return rankA - rankB
+		}
+	}
+
+	switch {
+	case len(a) > len(b):
+		if a[len(b)] == "**" {
+			return 1
+		}
+		return -1
+	case len(a) < len(b):
+		if b[len(a)] == "**" {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// About check if the path has all the scope segments in sequence (the default scope rules)
+func pathHasSegments(path string, scope string) bool {
+	pathSegments, scopeSegments := splitPath(path), splitPath(scope)
+	if len(scopeSegments) == 0 {
+		return false
+	}
+
+	for i := 0; i+len(scopeSegments) <= len(pathSegments); i++ {
+		if slices.Equal(pathSegments[i:i+len(scopeSegments)], scopeSegments) {
+			return true
+		}
+	}
+	return false
+}
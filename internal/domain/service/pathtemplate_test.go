@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+)
+
+func TestParsePathTemplate(t *testing.T) {
+	tests := []struct {
+		name		string
+		path		string
+		wantErr		error
+	}{
+		{name: "literal", path: "account/info"},
+		{name: "parameter", path: "account/{id}/info"},
+		{name: "one segment", path: "orders/*"},
+		{name: "any segments", path: "reports/**"},
+		{name: "leading and trailing slash", path: "/account/{id}/"},
+		{name: "empty", path: "/", wantErr: erro.ErrBadRequest},
+		{name: "empty segment", path: "account//info", wantErr: erro.ErrBadRequest},
+		{name: "any segments not last", path: "reports/**/daily", wantErr: erro.ErrBadRequest},
+		{name: "partial parameter", path: "account/id-{id}", wantErr: erro.ErrBadRequest},
+		{name: "partial wildcard", path: "orders/ord*", wantErr: erro.ErrBadRequest},
+		{name: "parameter invalid name", path: "account/{id-1}", wantErr: erro.ErrBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePathTemplate(tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parsePathTemplate(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPathTemplateMatch(t *testing.T) {
+	tests := []struct {
+		template	string
+		path		string
+		want		bool
+	}{
+		{template: "account/{id}/info", path: "account/123/info", want: true},
+		{template: "account/{id}/info", path: "/account/123/info/", want: true},
+		{template: "account/{id}/info", path: "account/123", want: false},
+		{template: "account/{id}/info", path: "account/123/info/detail", want: false},
+		{template: "account/{id}/info", path: "account/123/information", want: false},
+		{template: "orders/*", path: "orders/1", want: true},
+		{template: "orders/*", path: "orders", want: false},
+		{template: "orders/*", path: "orders/1/items", want: false},
+		{template: "reports/**", path: "reports", want: true},
+		{template: "reports/**", path: "reports/2024/daily", want: true},
+		{template: "reports/**", path: "reportsx/2024", want: false},
+		{template: "info", path: "account/info", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template+" "+tt.path, func(t *testing.T) {
+			template, err := parsePathTemplate(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := template.match(splitPath(tt.path)); got != tt.want {
+				t.Fatalf("match(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComparePathTemplates(t *testing.T) {
+	tests := []struct {
+		a			string
+		b			string
+		want		int // the sign, < 0 means a is more specific
+	}{
+		{a: "account/info", b: "account/{id}", want: -1},
+		{a: "account/{id}", b: "account/*", want: 0},
+		{a: "account/{id}", b: "account/**", want: -1},
+		{a: "account/{id}/info", b: "account/{id}", want: -1},
+		{a: "account/{id}", b: "account/{id}/**", want: -1},
+		{a: "account/{id}/**", b: "account/**", want: -1},
+		{a: "reports/**", b: "reports/daily", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			a, err := parsePathTemplate(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := parsePathTemplate(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := comparePathTemplates(a, b)
+			if (got < 0) != (tt.want < 0) || (got > 0) != (tt.want > 0) {
+				t.Fatalf("comparePathTemplates(%q, %q) = %d, want sign %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathHasSegments(t *testing.T) {
+	tests := []struct {
+		path		string
+		scope		string
+		want		bool
+	}{
+		{path: "account/info", scope: "info", want: true},
+		{path: "account/information", scope: "info", want: false},
+		{path: "info/account", scope: "info", want: true},
+		{path: "v1/account/info", scope: "account/info", want: true},
+		{path: "account/v1/info", scope: "account/info", want: false},
+		{path: "account", scope: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.scope, func(t *testing.T) {
+			if got := pathHasSegments(tt.path, tt.scope); got != tt.want {
+				t.Fatalf("pathHasSegments(%q, %q) = %v, want %v", tt.path, tt.scope, got, tt.want)
+			}
+		})
+	}
+}
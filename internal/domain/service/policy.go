@@ -0,0 +1,207 @@
+package service
+
+import (
+	"fmt"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// policyMethods are the methods allowed in a route rule (ANY means all the methods)
+var policyMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "ANY"}
+
+// defaultSuperscopes are the superscopes when the policy does not inform them
+var defaultSuperscopes = []string{"admin"}
+
+// defaultScopePolicy is used without a scope policy, only the default superscopes and the default rules
+var defaultScopePolicy = &scopePolicy{superscopes: defaultSuperscopes}
+
+// scopePolicy is the scope policy validated, the rules are sorted by the route precedence (the most specific path first)
+// Without routes the default rules are used, with the superscopes and the scope hierarchy of the policy
+type scopePolicy struct {
+	superscopes		[]string
+	hierarchy		map[string][]string
+	routes			[]routeRule
+}
+
+type routeRule struct {
+	path			string
+	template		pathTemplate
+	methods			[]string
+	scopes			[]string
+	condition		cel.Program
+}
+
+// About set the scope policy, the ScopeValidation uses the route rules (when informed) instead of the default rules
+func (w *WorkerService) SetScopePolicy(ctx context.Context, policy *model.ScopePolicy) error {
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","SetScopePolicy").Send()
+
+	scopePolicy, err := newScopePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	w.logger.Info().
+		Ctx(ctx).
+		Int("routes", len(scopePolicy.routes)).
+		Msg("scope policy loaded SUCCESSFULLY")
+
+	w.scopePolicy = scopePolicy
+
+	return nil
+}
+
+// About validate the scope policy, a invalid policy must stop the lambda
+func newScopePolicy(policy *model.ScopePolicy) (*scopePolicy, error) {
+	if policy == nil {
+		return nil, fmt.Errorf("%w: scope policy not informed", erro.ErrBadRequest)
+	}
+
+	// superscopes not informed are the default ones, superscopes: [] disables them
+	superscopes := policy.Superscopes
+	if superscopes == nil {
+		superscopes = defaultSuperscopes
+	}
+	for _, superscope := range superscopes {
+		if strings.TrimSpace(superscope) == "" {
+			return nil, fmt.Errorf("%w: scope policy with a empty superscope", erro.ErrBadRequest)
+		}
+	}
+
+	hierarchy, err := newScopeHierarchy(policy.ScopeHierarchy)
+	if err != nil {
+		return nil, err
+	}
+
+	conditionEnv, err := newConditionEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	scopePolicy := &scopePolicy{
+		superscopes: superscopes,
+		hierarchy: hierarchy,
+	}
+	routeMethods := make(map[string]bool)
+
+	for i, route := range policy.Routes {
+		path := strings.Trim(route.Path, "/")
+		if path == "" {
+			return nil, fmt.Errorf("%w: scope policy route[%d] without path", erro.ErrBadRequest, i)
+		}
+		template, err := parsePathTemplate(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(route.Methods) == 0 {
+			return nil, fmt.Errorf("%w: scope policy route %s without methods", erro.ErrBadRequest, route.Path)
+		}
+		if len(route.Scopes) == 0 {
+			return nil, fmt.Errorf("%w: scope policy route %s without scopes", erro.ErrBadRequest, route.Path)
+		}
+
+		methods := make([]string, 0, len(route.Methods))
+		for _, method := range route.Methods {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if !slices.Contains(policyMethods, method) {
+				return nil, fmt.Errorf("%w: scope policy route %s method %s not supported", erro.ErrBadRequest, route.Path, method)
+			}
+			// the same path (ex: account/{id} and account/*) and method in two rules is ambiguous
+			if routeMethods[template.key()+" "+method] {
+				return nil, fmt.Errorf("%w: scope policy route %s method %s duplicated", erro.ErrBadRequest, route.Path, method)
+			}
+			routeMethods[template.key()+" "+method] = true
+			methods = append(methods, method)
+		}
+
+		for _, scope := range route.Scopes {
+			if strings.TrimSpace(scope) == "" {
+				return nil, fmt.Errorf("%w: scope policy route %s with a empty scope", erro.ErrBadRequest, route.Path)
+			}
+		}
+
+		// the condition is compiled once, a invalid expression must stop the lambda
+		var condition cel.Program
+		if strings.TrimSpace(route.Condition) != "" {
+			condition, err = compileCondition(conditionEnv, route.Path, route.Condition)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		scopePolicy.routes = append(scopePolicy.routes, routeRule{
+			path: path,
+			template: template,
+			methods: methods,
+			scopes: route.Scopes,
+			condition: condition,
+		})
+	}
+
+	// deterministic precedence, the document order only between rules of the same precedence
+	slices.SortStableFunc(scopePolicy.routes, func(a, b routeRule) int {
+		return comparePathTemplates(a.template, b.template)
+	})
+
+	return scopePolicy, nil
+}
+
+// About get the most specific rule of the path and method
+func (p *scopePolicy) match(method string, path string) *routeRule {
+	segments := splitPath(path)
+	for i := range p.routes {
+		route := &p.routes[i]
+		if route.template.match(segments) && (slices.Contains(route.methods, "ANY") || slices.Contains(route.methods, method)) {
+			return route
+		}
+	}
+	return nil
+}
+
+// About check the granted scopes against the route rule (any scope of the rule and its condition)
+// The granted scopes are the token scopes expanded by the scope hierarchy and namespace wildcards (ex: account:*)
+// A superscope replaces the scopes of the rule, not its condition, and is allowed in a path without rule
+func (w *WorkerService) scopePolicyValidation(ctx context.Context,
+											  grantedScopes scopeSet,
+											  superscope bool,
+											  claims model.JwtData,
+											  arn string,
+											  requestData *model.RequestData,
+											  method string,
+											  path string) bool {
+	route := w.scopePolicy.match(method, path)
+	if route == nil {
+		if superscope {
+			w.logger.Debug().
+				Ctx(ctx).
+				Msg("++++++++++ TRUE SUPERSCOPE ++++++++++++++++++")
+			return true
+		}
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("method", method).
+			Str("path", path).
+			Msg("route NOT FOUND in the scope policy")
+		return false
+	}
+
+	if superscope || slices.ContainsFunc(route.scopes, grantedScopes.has) {
+		return w.conditionValidation(ctx, route, claims, arn, requestData)
+	}
+
+	w.logger.Warn().
+		Ctx(ctx).
+		Str("method", method).
+		Str("path", path).
+		Strs("scopes", route.scopes).
+		Msg("token scope NOT ALLOWED in the route")
+
+	return false
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"context"
+	"testing"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+const testArnPrefix = "arn:aws:execute-api:us-east-2:111111111111:api01/qa/"
+
+func TestNewScopePolicyInvalid(t *testing.T) {
+	route := model.RouteRule{Path: "account/{id}", Methods: []string{"GET"}, Scopes: []string{"account:read"}}
+
+	tests := []struct {
+		name		string
+		policy		*model.ScopePolicy
+	}{
+		{name: "nil", policy: nil},
+		{name: "empty superscope", policy: &model.ScopePolicy{Superscopes: []string{" "}, Routes: []model.RouteRule{route}}},
+		{name: "without path", policy: &model.ScopePolicy{Routes: []model.RouteRule{{Path: "/", Methods: []string{"GET"}, Scopes: []string{"info"}}}}},
+		{name: "invalid template", policy: &model.ScopePolicy{Routes: []model.RouteRule{{Path: "reports/**/daily", Methods: []string{"GET"}, Scopes: []string{"info"}}}}},
+		{name: "without methods", policy: &model.ScopePolicy{Routes: []model.RouteRule{{Path: "info", Scopes: []string{"info"}}}}},
+		{name: "method not supported", policy: &model.ScopePolicy{Routes: []model.RouteRule{{Path: "info", Methods: []string{"TRACE"}, Scopes: []string{"info"}}}}},
+		{name: "without scopes", policy: &model.ScopePolicy{Routes: []model.RouteRule{{Path: "info", Methods: []string{"GET"}}}}},
+		{name: "empty scope", policy: &model.ScopePolicy{Routes: []model.RouteRule{{Path: "info", Methods: []string{"GET"}, Scopes: []string{""}}}}},
+		{name: "duplicated path and method", policy: &model.ScopePolicy{Routes: []model.RouteRule{
+			route,
+			{Path: "account/*", Methods: []string{"get"}, Scopes: []string{"account:admin"}},
+		}}},
+		{name: "hierarchy cycle", policy: &model.ScopePolicy{
+			ScopeHierarchy: map[string][]string{"account:write": {"account:admin"}, "account:admin": {"account:write"}},
+			Routes: []model.RouteRule{route},
+		}},
+		{name: "invalid condition", policy: &model.ScopePolicy{Routes: []model.RouteRule{
+			{Path: "info", Methods: []string{"GET"}, Scopes: []string{"info"}, Condition: "claims.tier =="},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newScopePolicy(tt.policy); !errors.Is(err, erro.ErrBadRequest) {
+				t.Fatalf("newScopePolicy() error = %v, want %v", err, erro.ErrBadRequest)
+			}
+		})
+	}
+}
+
+func TestScopePolicyMatch(t *testing.T) {
+	policy, err := newScopePolicy(&model.ScopePolicy{
+		Routes: []model.RouteRule{
+			{Path: "account/**", Methods: []string{"ANY"}, Scopes: []string{"account:admin"}},
+			{Path: "account/{id}", Methods: []string{"GET"}, Scopes: []string{"account:read"}},
+			{Path: "account/{id}/info", Methods: []string{"GET"}, Scopes: []string{"info"}},
+			{Path: "account/summary", Methods: []string{"GET"}, Scopes: []string{"account:summary"}},
+			{Path: "orders/*", Methods: []string{"POST", "PUT"}, Scopes: []string{"orders:write"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method		string
+		path		string
+		want		string // the path of the matched rule, empty when not found
+	}{
+		{method: "GET", path: "account/summary", want: "account/summary"},
+		{method: "GET", path: "account/123", want: "account/{id}"},
+		{method: "GET", path: "account/123/info", want: "account/{id}/info"},
+		{method: "DELETE", path: "account/123", want: "account/**"},
+		{method: "GET", path: "account/123/info/detail", want: "account/**"},
+		{method: "GET", path: "account", want: "account/**"},
+		{method: "PUT", path: "orders/1", want: "orders/*"},
+		{method: "GET", path: "orders/1", want: ""},
+		{method: "POST", path: "orders/1/items", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			got := ""
+			if route := policy.match(tt.method, tt.path); route != nil {
+				got = route.path
+			}
+			if got != tt.want {
+				t.Fatalf("match(%s, %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeValidation(t *testing.T) {
+	policy := &model.ScopePolicy{
+		Superscopes: []string{"admin"},
+		ScopeHierarchy: map[string][]string{
+			"account:admin": {"account:write"},
+			"account:write": {"account:read"},
+		},
+		Routes: []model.RouteRule{
+			{Path: "account/{id}", Methods: []string{"GET"}, Scopes: []string{"account:read"}},
+			{Path: "account/{id}", Methods: []string{"DELETE"}, Scopes: []string{"account:admin"}},
+			{Path: "account/{id}/info", Methods: []string{"GET"}, Scopes: []string{"info"}},
+			{Path: "reports/**", Methods: []string{"GET"}, Scopes: []string{"reports:read"}, Condition: `claims.tier == "tier1"`},
+		},
+	}
+
+	// without routes the default rules are used with the hierarchy and superscopes of the policy
+	hierarchyPolicy := &model.ScopePolicy{
+		Superscopes: []string{"root"},
+		ScopeHierarchy: policy.ScopeHierarchy,
+	}
+
+	tests := []struct {
+		name		string
+		policy		*model.ScopePolicy
+		scopes		[]string
+		tier		string
+		arn			string
+		want		bool
+	}{
+		// default rules (no scope policy)
+		{name: "default admin", scopes: []string{"admin"}, arn: "DELETE/account/123", want: true},
+		{name: "default path scope", scopes: []string{"info"}, arn: "GET/account/info", want: true},
+		{name: "default path scope not a segment", scopes: []string{"info"}, arn: "GET/account/information", want: false},
+		{name: "default path and method", scopes: []string{"account:read"}, arn: "GET/account", want: true},
+		{name: "default path and other method", scopes: []string{"account:read"}, arn: "POST/account", want: false},
+		{name: "default write is post and put", scopes: []string{"account:write"}, arn: "PUT/account", want: true},
+		{name: "default method any", scopes: []string{"account:read"}, arn: "ANY/account", want: true},
+		{name: "default without scope", arn: "GET/account", want: false},
+		{name: "default namespace wildcard", scopes: []string{"account:*"}, arn: "DELETE/account", want: true},
+		{name: "default hierarchy", policy: hierarchyPolicy, scopes: []string{"account:admin"}, arn: "POST/account", want: true},
+		{name: "default hierarchy is not upwards", policy: hierarchyPolicy, scopes: []string{"account:read"}, arn: "POST/account", want: false},
+		{name: "default hierarchy superscope", policy: hierarchyPolicy, scopes: []string{"root"}, arn: "DELETE/orders", want: true},
+		{name: "default hierarchy admin not a superscope", policy: hierarchyPolicy, scopes: []string{"admin"}, arn: "DELETE/orders", want: false},
+		// scope policy
+		{name: "policy superscope", policy: policy, scopes: []string{"admin"}, arn: "DELETE/orders/1", want: true},
+		{name: "policy default superscope", policy: &model.ScopePolicy{Routes: policy.Routes}, scopes: []string{"admin"}, arn: "DELETE/orders/1", want: true},
+		{name: "policy superscopes disabled", policy: &model.ScopePolicy{Superscopes: []string{}, Routes: policy.Routes}, scopes: []string{"admin"}, arn: "DELETE/orders/1", want: false},
+		{name: "policy route scope", policy: policy, scopes: []string{"account:read"}, arn: "GET/account/123", want: true},
+		{name: "policy route other method", policy: policy, scopes: []string{"account:read"}, arn: "DELETE/account/123", want: false},
+		{name: "policy hierarchy", policy: policy, scopes: []string{"account:admin"}, arn: "GET/account/123", want: true},
+		{name: "policy namespace wildcard", policy: policy, scopes: []string{"account:*"}, arn: "DELETE/account/123", want: true},
+		{name: "policy hierarchy is not upwards", policy: policy, scopes: []string{"account:write"}, arn: "DELETE/account/123", want: false},
+		{name: "policy route not found", policy: policy, scopes: []string{"info"}, arn: "GET/info", want: false},
+		{name: "policy condition true", policy: policy, scopes: []string{"reports:read"}, tier: "tier1", arn: "GET/reports/2024/daily", want: true},
+		{name: "policy condition false", policy: policy, scopes: []string{"reports:read"}, tier: "tier2", arn: "GET/reports/2024/daily", want: false},
+		{name: "policy superscope condition true", policy: policy, scopes: []string{"admin"}, tier: "tier1", arn: "GET/reports/2024/daily", want: true},
+		{name: "policy superscope condition false", policy: policy, scopes: []string{"admin"}, tier: "tier2", arn: "GET/reports/2024/daily", want: false},
+		{name: "policy superscope without condition", policy: policy, scopes: []string{"admin"}, tier: "tier2", arn: "DELETE/account/123", want: true},
+		{name: "malformed arn", scopes: []string{"admin"}, arn: "GET", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorkerService(t, &model.AppServer{})
+			if tt.policy != nil {
+				if err := w.SetScopePolicy(context.Background(), tt.policy); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			claims := model.JwtData{Scope: tt.scopes, Tier: tt.tier}
+			if got := w.ScopeValidation(context.Background(), claims, testArnPrefix + tt.arn, &model.RequestData{}); got != tt.want {
+				t.Fatalf("ScopeValidation(%s) = %v, want %v", tt.arn, got, tt.want)
+			}
+		})
+	}
+}
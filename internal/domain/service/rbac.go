@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/shared/cache"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CredentialScopeStore gets the current scopes (roles) of a user, nil when the user has none
+type CredentialScopeStore interface {
+	GetCredentialScope(ctx context.Context, user string) (*model.CredentialScope, error)
+}
+
+// About set the credential scope store, the scopes are cached by username
+func (w *WorkerService) SetCredentialScopeStore(credentialScopeStore CredentialScopeStore) {
+	w.credentialScopeStore = credentialScopeStore
+
+	cacheSize := 0
+	if w.appServer.Rbac != nil {
+		cacheSize = w.appServer.Rbac.CacheSize
+	}
+	w.credentialScopeCache = cache.NewCache[model.ScopeList](cacheSize)
+}
+
+// About keep only the token scopes also granted by the current user scopes of the store (fail closed when the store is not reachable)
+// So a scope removed from the user is denied without waiting the token expire, and the token never gets a scope it was not issued with
+// The store has the users of the local issuer, a token of other trusted issuer keeps its scopes
+func (w *WorkerService) CredentialScopeValidation(ctx context.Context, claims *model.JwtData) error {
+	if w.appServer.Rbac == nil || !w.appServer.Rbac.Enabled || w.credentialScopeStore == nil {
+		return nil
+	}
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","CredentialScopeValidation").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.CredentialScopeValidation", trace.SpanKindServer)
+	defer span.End()
+
+	if !w.localIssuer(claims.ISS) {
+		w.logger.Debug().
+			Ctx(ctx).
+			Str("iss", claims.ISS).
+			Msg("token of other trusted issuer, user scopes not loaded")
+		return nil
+	}
+
+	if claims.Username == "" {
+		w.logger.Warn().
+			Ctx(ctx).
+			Msg("token username MISSING, user scopes can not be loaded")
+		return erro.ErrTokenClaimMissing
+	}
+
+	scopes, ok := w.credentialScopeCache.Get(claims.Username)
+	if !ok {
+		credentialScope, err := w.credentialScopeStore.GetCredentialScope(ctx, claims.Username)
+		if err != nil {
+			w.logger.Error().
+				Ctx(ctx).
+				Err(err).
+				Str("username", claims.Username).
+				Msg("erro load the user scopes")
+			return erro.ErrCredentialScope
+		}
+
+		// a user without scope item has no scope
+		scopes = model.ScopeList{}
+		if credentialScope != nil {
+			scopes = normalizeScopes(credentialScope.Scope)
+		}
+		w.credentialScopeCache.Set(claims.Username, scopes, w.appServer.Rbac.CacheTTL)
+	}
+
+	// the user scopes are expanded by the scope hierarchy (ex: account:admin grants the token account:read)
+	policy := w.scopePolicy
+	if policy == nil {
+		policy = defaultScopePolicy
+	}
+	userScopes := policy.expandScopes(scopes)
+
+	tokenScopes := model.ScopeList{}
+	for _, scope := range claims.Scope {
+		if userScopes.has(scope) {
+			tokenScopes = append(tokenScopes, scope)
+		}
+	}
+
+	w.logger.Debug().
+		Ctx(ctx).
+		Str("username", claims.Username).
+		Strs("token_scopes", claims.Scope).
+		Strs("user_scopes", scopes).
+		Strs("scopes", tokenScopes).
+		Msg("token scopes restricted to the user scopes")
+
+	claims.Scope = tokenScopes
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"sync"
+	"time"
+	"errors"
+	"slices"
+	"context"
+	"testing"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// testCredentialScopeStore has the user scopes by username and counts the reads
+type testCredentialScopeStore struct {
+	mutex		sync.Mutex
+	scopes		map[string][]string
+	err			error
+	reads		int
+}
+
+func (s *testCredentialScopeStore) GetCredentialScope(ctx context.Context, user string) (*model.CredentialScope, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.reads++
+	if s.err != nil {
+		return nil, s.err
+	}
+	scopes, ok := s.scopes[user]
+	if !ok {
+		return nil, nil
+	}
+	return &model.CredentialScope{ID: "USER-" + user, SK: "SCOPE-001", User: user, Scope: scopes}, nil
+}
+
+func (s *testCredentialScopeStore) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.reads
+}
+
+func newRbacWorkerService(t *testing.T, store CredentialScopeStore) *WorkerService {
+	t.Helper()
+
+	w := newTestWorkerService(t, &model.AppServer{
+		Rbac: &model.Rbac{Enabled: true, CacheTTL: time.Minute, CacheSize: 10},
+	})
+	w.SetCredentialScopeStore(store)
+	return w
+}
+
+func TestCredentialScopeValidation(t *testing.T) {
+	store := &testCredentialScopeStore{scopes: map[string][]string{
+		"user-01": {"account:read", "info", "admin"},
+		"user-02": {"account:admin"},
+		"user-03": {"account:*"},
+		"user-04": {},
+	}}
+
+	policy := &model.ScopePolicy{
+		ScopeHierarchy: map[string][]string{
+			"account:admin": {"account:write"},
+			"account:write": {"account:read"},
+		},
+	}
+
+	tests := []struct {
+		name		string
+		username	string
+		scopes		[]string
+		want		[]string
+		wantErr		error
+	}{
+		{name: "scopes of both", username: "user-01", scopes: []string{"account:read", "orders:write"}, want: []string{"account:read"}},
+		{name: "role not in the token", username: "user-01", scopes: []string{"info"}, want: []string{"info"}},
+		{name: "role hierarchy", username: "user-02", scopes: []string{"account:read", "account:write"}, want: []string{"account:read", "account:write"}},
+		{name: "role hierarchy is not upwards", username: "user-01", scopes: []string{"account:write"}, want: []string{}},
+		{name: "role namespace wildcard", username: "user-03", scopes: []string{"account:read", "orders:read"}, want: []string{"account:read"}},
+		{name: "user without scopes", username: "user-04", scopes: []string{"info"}, want: []string{}},
+		{name: "user without scope item", username: "user-05", scopes: []string{"info"}, want: []string{}},
+		{name: "username missing", scopes: []string{"info"}, wantErr: erro.ErrTokenClaimMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newRbacWorkerService(t, store)
+			if err := w.SetScopePolicy(context.Background(), policy); err != nil {
+				t.Fatal(err)
+			}
+
+			claims := &model.JwtData{Username: tt.username, Scope: tt.scopes}
+			err := w.CredentialScopeValidation(context.Background(), claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CredentialScopeValidation() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !slices.Equal(claims.Scope, tt.want) {
+				t.Fatalf("scopes = %v, want %v", claims.Scope, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialScopeValidationStore(t *testing.T) {
+	store := &testCredentialScopeStore{scopes: map[string][]string{"user-01": {"info"}}}
+	w := newRbacWorkerService(t, store)
+
+	// the user scopes are cached
+	for range 2 {
+		claims := &model.JwtData{Username: "user-01", Scope: []string{"info", "admin"}}
+		if err := w.CredentialScopeValidation(context.Background(), claims); err != nil {
+			t.Fatalf("CredentialScopeValidation() error = %v", err)
+		}
+		if !slices.Equal(claims.Scope, []string{"info"}) {
+			t.Fatalf("scopes = %v, want [info]", claims.Scope)
+		}
+	}
+	if got := store.count(); got != 1 {
+		t.Fatalf("store reads = %d, want 1", got)
+	}
+
+	// fail closed when the store is not reachable
+	store.err = erro.ErrNotFound
+	claims := &model.JwtData{Username: "user-02", Scope: []string{"info"}}
+	if err := w.CredentialScopeValidation(context.Background(), claims); !errors.Is(err, erro.ErrCredentialScope) {
+		t.Fatalf("CredentialScopeValidation() error = %v, want %v", err, erro.ErrCredentialScope)
+	}
+}
+
+func TestCredentialScopeValidationIssuer(t *testing.T) {
+	store := &testCredentialScopeStore{scopes: map[string][]string{"user-01": {"info"}}}
+	w := newRbacWorkerService(t, store)
+
+	// the trusted issuers, only the local one has its users in the store
+	w.trustedIssuers = map[string]*issuerTrust{
+		"https://local.example.com": {trustedIssuer: model.TrustedIssuer{Issuer: "https://local.example.com", KeySource: "local"}},
+		"https://remote.example.com": {trustedIssuer: model.TrustedIssuer{Issuer: "https://remote.example.com", KeySource: "oidc"}},
+	}
+
+	tests := []struct {
+		name		string
+		iss			string
+		want		[]string
+		wantReads	int
+	}{
+		{name: "local issuer", iss: "https://local.example.com", want: []string{"info"}, wantReads: 1},
+		{name: "other issuer same username", iss: "https://remote.example.com", want: []string{"info", "admin"}, wantReads: 1},
+		{name: "local issuer cached", iss: "https://local.example.com", want: []string{"info"}, wantReads: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &model.JwtData{ISS: tt.iss, Username: "user-01", Scope: []string{"info", "admin"}}
+			if err := w.CredentialScopeValidation(context.Background(), claims); err != nil {
+				t.Fatalf("CredentialScopeValidation() error = %v", err)
+			}
+			if !slices.Equal(claims.Scope, tt.want) {
+				t.Fatalf("scopes = %v, want %v", claims.Scope, tt.want)
+			}
+			if got := store.count(); got != tt.wantReads {
+				t.Fatalf("store reads = %d, want %d", got, tt.wantReads)
+			}
+		})
+	}
+}
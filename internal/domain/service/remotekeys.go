@@ -0,0 +1,147 @@
+package service
+
+import (
+	"sync"
+	"time"
+	"context"
+	"sync/atomic"
+
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// KeySource fetches a remote jwks and how long it can be cached (zero when not informed, negative when it must not be cached)
+type KeySource interface {
+	FetchJwks(ctx context.Context) (*model.Jwks, time.Duration, error)
+}
+
+// remoteKeys controls the cache of the remote jwks
+type remoteKeys struct {
+	mutex			sync.Mutex
+	keySource		KeySource
+	keySets			map[string]*KeySet
+	expiresAt		time.Time
+	lastUnknownKid	time.Time
+	refreshing		atomic.Bool
+	oidc			model.Oidc
+}
+
+// jwkTypes is the jwk kty of each authentication model key set
+var jwkTypes = map[string]string{
+	"RSA":		"RSA",
+	"ECDSA":	"EC",
+	"EDDSA":	"OKP",
+}
+
+// About set the remote key source and fetch the jwks
+func (w *WorkerService) SetKeySource(ctx context.Context, keySource KeySource) error {
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","SetKeySource").Send()
+
+	oidc := model.Oidc{}
+	if w.appServer.Oidc != nil {
+		oidc = *w.appServer.Oidc
+	}
+
+	w.remoteKeys = &remoteKeys{
+		keySource: keySource,
+		keySets: w.keySets,
+		oidc: oidc,
+	}
+
+	return w.remoteKeys.fetch(ctx, w)
+}
+
+// About refresh the remote jwks in the request when the cache expired
+// The requests arriving during the refresh use the current keys, as a failed refresh does
+func (r *remoteKeys) refreshIfExpired(ctx context.Context, w *WorkerService) {
+	r.mutex.Lock()
+	expired := time.Now().After(r.expiresAt)
+	r.mutex.Unlock()
+
+	if !expired || !r.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	defer r.refreshing.Store(false)
+
+	if err := r.fetch(ctx, w); err != nil {
+		w.logger.Error().
+			Ctx(ctx).
+			Err(err).
+			Msg("erro refresh remote jwks, the current keys are kept")
+	}
+}
+
+// About fetch the remote jwks now because the token kid is unknown
+// It is rate limited by the unknown kid interval, returns true when the jwks was fetched
+func (r *remoteKeys) refreshUnknownKid(ctx context.Context, w *WorkerService, kid string) bool {
+	r.mutex.Lock()
+	if time.Since(r.lastUnknownKid) < r.oidc.UnknownKidInterval {
+		r.mutex.Unlock()
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("kid", kid).
+			Msg("unknown kid, remote jwks fetch RATE LIMITED")
+		return false
+	}
+	r.lastUnknownKid = time.Now()
+	r.mutex.Unlock()
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("kid", kid).
+		Msg("unknown kid, fetching the remote jwks")
+
+	if err := r.fetch(ctx, w); err != nil {
+		w.logger.Error().
+			Ctx(ctx).
+			Err(err).
+			Msg("erro fetch remote jwks")
+		return false
+	}
+	return true
+}
+
+// About fetch the jwks and reload the key sets, the cache ttl is bounded by the min/max configured
+func (r *remoteKeys) fetch(ctx context.Context, w *WorkerService) error {
+	jwks, ttl, err := r.keySource.FetchJwks(ctx)
+	if err != nil {
+		// retry after the min ttl, avoiding fetch in every request
+		r.mutex.Lock()
+		r.expiresAt = time.Now().Add(r.oidc.MinCacheTTL)
+		r.mutex.Unlock()
+		return err
+	}
+
+	// not cached by the source (no-store, no-cache) uses the min ttl, not informed uses the default ttl
+	switch {
+	case ttl < 0:
+		ttl = r.oidc.MinCacheTTL
+	case ttl == 0:
+		ttl = r.oidc.DefaultCacheTTL
+	}
+	if ttl < r.oidc.MinCacheTTL {
+		ttl = r.oidc.MinCacheTTL
+	}
+	if r.oidc.MaxCacheTTL > 0 && ttl > r.oidc.MaxCacheTTL {
+		ttl = r.oidc.MaxCacheTTL
+	}
+
+	for authModel, kty := range jwkTypes {
+		if keySet, ok := r.keySets[authModel]; ok {
+			keySet.ReloadJwks(jwks, kty, w.logger)
+		}
+	}
+
+	r.mutex.Lock()
+	r.expiresAt = time.Now().Add(ttl)
+	r.mutex.Unlock()
+
+	w.logger.Info().
+		Ctx(ctx).
+		Int("keys", len(jwks.JwtKeyInfo)).
+		Dur("ttl", ttl).
+		Msg("remote jwks loaded")
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"sync"
+	"time"
+	"errors"
+	"context"
+	"testing"
+	"math/big"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// testKeySource is a remote jwks stand-in, the published kids can be changed (key rotation)
+type testKeySource struct {
+	mutex			sync.Mutex
+	keys			map[string]*rsa.PrivateKey
+	published		[]string
+	ttl				time.Duration
+	fetches			int
+}
+
+func newTestKeySource(t *testing.T, ttl time.Duration, kids ...string) *testKeySource {
+	t.Helper()
+
+	keySource := &testKeySource{
+		keys: make(map[string]*rsa.PrivateKey),
+		ttl: ttl,
+	}
+	for _, kid := range kids {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keySource.keys[kid] = key
+	}
+	return keySource
+}
+
+func (s *testKeySource) FetchJwks(ctx context.Context) (*model.Jwks, time.Duration, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.fetches++
+	jwks := &model.Jwks{}
+	for _, kid := range s.published {
+		jwks.JwtKeyInfo = append(jwks.JwtKeyInfo, model.JwtKeyInfo{
+			Type: "RSA",
+			Use: "sig",
+			Kid: kid,
+			NBase64: base64.RawURLEncoding.EncodeToString(s.keys[kid].N.Bytes()),
+			EBase64: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.keys[kid].E)).Bytes()),
+		})
+	}
+	return jwks, s.ttl, nil
+}
+
+func (s *testKeySource) publish(kids ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.published = kids
+}
+
+func (s *testKeySource) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.fetches
+}
+
+func (s *testKeySource) sign(t *testing.T, kid string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"username": "user-01",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(s.keys[kid])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func newRemoteKeysWorkerService(t *testing.T, keySource *testKeySource, oidc *model.Oidc) *WorkerService {
+	t.Helper()
+
+	w := newTestWorkerService(t, &model.AppServer{
+		RsaKey: &model.RsaKey{},
+		TokenValidation: map[string]*model.TokenValidation{"RSA": {AllowedAlgorithms: []string{"RS256"}}},
+		Oidc: oidc,
+	})
+
+	if err := w.SetKeySource(context.Background(), keySource); err != nil {
+		t.Fatalf("SetKeySource() error = %v", err)
+	}
+	return w
+}
+
+func TestRemoteKeysUnknownKid(t *testing.T) {
+	keySource := newTestKeySource(t, 10 * time.Minute, "k1", "k2", "k3")
+	keySource.publish("k1")
+
+	w := newRemoteKeysWorkerService(t, keySource, &model.Oidc{
+		DefaultCacheTTL: 15 * time.Minute,
+		MinCacheTTL: time.Minute,
+		MaxCacheTTL: time.Hour,
+		UnknownKidInterval: time.Hour,
+	})
+
+	// k2 is rotated in after the first fetch, k3 is never published
+	keySource.publish("k1", "k2")
+
+	tests := []struct {
+		name		string
+		kid			string
+		wantErr		error
+		wantFetches	int
+	}{
+		{name: "kid cached", kid: "k1", wantFetches: 1},
+		{name: "kid rotated fetches the jwks", kid: "k2", wantFetches: 2},
+		{name: "kid rotated now cached", kid: "k2", wantFetches: 2},
+		{name: "unknown kid rate limited", kid: "k3", wantErr: erro.ErrKidNotFound, wantFetches: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := w.tokenValidationRSA(context.Background(), keySource.sign(t, tt.kid))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("tokenValidationRSA() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := keySource.count(); got != tt.wantFetches {
+				t.Fatalf("jwks fetches = %d, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestRemoteKeysCacheTTL(t *testing.T) {
+	oidc := model.Oidc{
+		DefaultCacheTTL: 15 * time.Minute,
+		MinCacheTTL: time.Minute,
+		MaxCacheTTL: time.Hour,
+		UnknownKidInterval: time.Hour,
+	}
+
+	tests := []struct {
+		name			string
+		ttl				time.Duration
+		wantTTL			time.Duration
+	}{
+		{name: "source ttl", ttl: 10 * time.Minute, wantTTL: 10 * time.Minute},
+		{name: "source ttl above the max", ttl: 24 * time.Hour, wantTTL: time.Hour},
+		{name: "source ttl below the min", ttl: 5 * time.Second, wantTTL: time.Minute},
+		{name: "not informed uses the default", ttl: 0, wantTTL: 15 * time.Minute},
+		{name: "not cached uses the min", ttl: -1, wantTTL: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keySource := newTestKeySource(t, tt.ttl, "k1")
+			keySource.publish("k1")
+
+			oidc := oidc
+			w := newRemoteKeysWorkerService(t, keySource, &oidc)
+
+			w.remoteKeys.mutex.Lock()
+			ttl := time.Until(w.remoteKeys.expiresAt)
+			w.remoteKeys.mutex.Unlock()
+
+			if ttl > tt.wantTTL || ttl < tt.wantTTL - 5 * time.Second {
+				t.Fatalf("cache ttl = %v, want %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestRemoteKeysCacheExpired(t *testing.T) {
+	keySource := newTestKeySource(t, 10 * time.Minute, "k1", "k2")
+	keySource.publish("k1")
+
+	w := newRemoteKeysWorkerService(t, keySource, &model.Oidc{
+		DefaultCacheTTL: 15 * time.Minute,
+		MinCacheTTL: time.Minute,
+		MaxCacheTTL: time.Hour,
+		UnknownKidInterval: time.Hour,
+	})
+
+	// k2 is rotated in, the unknown kid fetch is rate limited so only the expired cache can load it
+	keySource.publish("k1", "k2")
+	w.remoteKeys.mutex.Lock()
+	w.remoteKeys.lastUnknownKid = time.Now()
+	w.remoteKeys.mutex.Unlock()
+
+	if _, err := w.tokenValidationRSA(context.Background(), keySource.sign(t, "k2")); !errors.Is(err, erro.ErrKidNotFound) {
+		t.Fatalf("tokenValidationRSA() cached error = %v, want %v", err, erro.ErrKidNotFound)
+	}
+
+	w.remoteKeys.mutex.Lock()
+	w.remoteKeys.expiresAt = time.Now().Add(-time.Second)
+	w.remoteKeys.mutex.Unlock()
+
+	if _, err := w.tokenValidationRSA(context.Background(), keySource.sign(t, "k2")); err != nil {
+		t.Fatalf("tokenValidationRSA() expired error = %v, want nil", err)
+	}
+	if got := keySource.count(); got != 2 {
+		t.Fatalf("jwks fetches = %d, want 2", got)
+	}
+}
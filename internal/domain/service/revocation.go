@@ -0,0 +1,94 @@
+package service
+
+import (
+	"slices"
+	"context"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/shared/cache"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RevocationStore checks if a token id (jti or jwt_id) was revoked
+type RevocationStore interface {
+	IsRevoked(ctx context.Context, tokenId string) (bool, error)
+}
+
+// About set the revocation store, the result is cached by token id
+func (w *WorkerService) SetRevocationStore(revocationStore RevocationStore) {
+	w.revocationStore = revocationStore
+
+	cacheSize := 0
+	if w.appServer.Revocation != nil {
+		cacheSize = w.appServer.Revocation.CacheSize
+	}
+	w.revocationCache = cache.NewCache[bool](cacheSize)
+}
+
+// About check if the token ids were revoked (fail closed when the store is not reachable)
+// The standard jti and the jwt_id claim are both checked, the token is revoked by any of them
+func (w *WorkerService) RevocationValidation(ctx context.Context, claims model.JwtData) error {
+	if w.appServer.Revocation == nil || !w.appServer.Revocation.Enabled || w.revocationStore == nil {
+		return nil
+	}
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","RevocationValidation").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.RevocationValidation", trace.SpanKindServer)
+	defer span.End()
+
+	tokenIds := []string{}
+	for _, tokenId := range []string{claims.ID, claims.JwtId} {
+		if tokenId != "" && !slices.Contains(tokenIds, tokenId) {
+			tokenIds = append(tokenIds, tokenId)
+		}
+	}
+
+	if len(tokenIds) == 0 {
+		w.logger.Warn().
+			Ctx(ctx).
+			Msg("token jti and jwt_id MISSING, revocation can not be checked")
+		return erro.ErrTokenClaimMissing
+	}
+
+	for _, tokenId := range tokenIds {
+		if err := w.tokenIdRevocation(ctx, tokenId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// About check a token id in the revocation cache, or in the store when not cached
+func (w *WorkerService) tokenIdRevocation(ctx context.Context, tokenId string) error {
+	revoked, ok := w.revocationCache.Get(tokenId)
+	if !ok {
+		var err error
+		revoked, err = w.revocationStore.IsRevoked(ctx, tokenId)
+		if err != nil {
+			w.logger.Error().
+				Ctx(ctx).
+				Err(err).
+				Str("token_id", tokenId).
+				Msg("erro check token revocation")
+			return erro.ErrRevocationCheck
+		}
+		w.revocationCache.Set(tokenId, revoked, w.appServer.Revocation.CacheTTL)
+	}
+
+	if revoked {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("token_id", tokenId).
+			Msg("token REVOKED")
+		return erro.ErrTokenRevoked
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"time"
+	"errors"
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// testRevocationStore is a in-memory denylist
+type testRevocationStore map[string]bool
+
+func newTestRevocationStore(tokenIds ...string) testRevocationStore {
+	revoked := make(testRevocationStore)
+	for _, tokenId := range tokenIds {
+		revoked[tokenId] = true
+	}
+	return revoked
+}
+
+func (s testRevocationStore) IsRevoked(ctx context.Context, tokenId string) (bool, error) {
+	return s[tokenId], nil
+}
+
+// failingRevocationStore is a store that can not be reached
+type failingRevocationStore struct{}
+
+func (failingRevocationStore) IsRevoked(ctx context.Context, tokenId string) (bool, error) {
+	return false, errors.New("dynamo unavailable")
+}
+
+func TestRevocationValidation(t *testing.T) {
+	tests := []struct {
+		name	string
+		store	RevocationStore
+		claims	model.JwtData
+		wantErr	error
+	}{
+		{
+			name: "jti not revoked",
+			store: newTestRevocationStore("revoked-id"),
+			claims: model.JwtData{RegisteredClaims: jwt.RegisteredClaims{ID: "valid-id"}},
+		},
+		{
+			name: "jti revoked",
+			store: newTestRevocationStore("revoked-id"),
+			claims: model.JwtData{RegisteredClaims: jwt.RegisteredClaims{ID: "revoked-id"}},
+			wantErr: erro.ErrTokenRevoked,
+		},
+		{
+			name: "jwt_id revoked without jti",
+			store: newTestRevocationStore("revoked-id"),
+			claims: model.JwtData{JwtId: "revoked-id"},
+			wantErr: erro.ErrTokenRevoked,
+		},
+		{
+			name: "jti revoked with jwt_id",
+			store: newTestRevocationStore("revoked-id"),
+			claims: model.JwtData{JwtId: "valid-id", RegisteredClaims: jwt.RegisteredClaims{ID: "revoked-id"}},
+			wantErr: erro.ErrTokenRevoked,
+		},
+		{
+			name: "jwt_id revoked with jti",
+			store: newTestRevocationStore("revoked-id"),
+			claims: model.JwtData{JwtId: "revoked-id", RegisteredClaims: jwt.RegisteredClaims{ID: "valid-id"}},
+			wantErr: erro.ErrTokenRevoked,
+		},
+		{
+			name: "jti and jwt_id missing",
+			store: newTestRevocationStore("revoked-id"),
+			claims: model.JwtData{},
+			wantErr: erro.ErrTokenClaimMissing,
+		},
+		{
+			name: "store unavailable fails closed",
+			store: failingRevocationStore{},
+			claims: model.JwtData{RegisteredClaims: jwt.RegisteredClaims{ID: "valid-id"}},
+			wantErr: erro.ErrRevocationCheck,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorkerService(t, &model.AppServer{
+				Revocation: &model.Revocation{Enabled: true, CacheTTL: time.Minute, CacheSize: 10},
+			})
+			w.SetRevocationStore(tt.store)
+
+			err := w.RevocationValidation(context.Background(), tt.claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RevocationValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRevocationValidationDisabled(t *testing.T) {
+	w := newTestWorkerService(t, &model.AppServer{
+		Revocation: &model.Revocation{Enabled: false},
+	})
+	w.SetRevocationStore(newTestRevocationStore("revoked-id"))
+
+	claims := model.JwtData{RegisteredClaims: jwt.RegisteredClaims{ID: "revoked-id"}}
+	if err := w.RevocationValidation(context.Background(), claims); err != nil {
+		t.Fatalf("RevocationValidation() error = %v, want nil when disabled", err)
+	}
+}
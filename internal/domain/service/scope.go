@@ -0,0 +1,65 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// defaultScopeClaims are the claims with the scopes when not configured
+// scope is the OAuth2 space separated string (or array) and scp is the azure style array
+var defaultScopeClaims = []string{"scope", "scp"}
+
+// About normalise the token scopes before the scope validation
+// The scopes of all configured claims are merged (in order), trimmed and deduplicated
+func (w *WorkerService) scopeNormalization(claims *model.JwtData) {
+	scopeClaims := defaultScopeClaims
+	if w.appServer.ClaimValidation != nil && len(w.appServer.ClaimValidation.ScopeClaims) > 0 {
+		scopeClaims = w.appServer.ClaimValidation.ScopeClaims
+	}
+
+	// only the scope claim, already decoded as array or string
+	if len(scopeClaims) == 1 && scopeClaims[0] == "scope" {
+		claims.Scope = normalizeScopes(claims.Scope)
+		return
+	}
+
+	// the other scope claims (ex: scp) are not JwtData fields, so they are read from the payload
+	scopes := []string{}
+	for _, scopeClaim := range scopeClaims {
+		scopes = append(scopes, claimStrings(claims.RawClaims[scopeClaim])...)
+	}
+	claims.Scope = normalizeScopes(scopes)
+}
+
+// About trim, remove empty and duplicated scopes keeping the order
+func normalizeScopes(scopes []string) model.ScopeList {
+	normalized := make(model.ScopeList, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		if scope = strings.TrimSpace(scope); scope == "" || seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		normalized = append(normalized, scope)
+	}
+	return normalized
+}
+
+// About a claim as list, a space separated string or a array
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, strings.Fields(s)...)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
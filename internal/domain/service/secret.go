@@ -0,0 +1,128 @@
+package service
+
+import (
+	"sync"
+	"time"
+	"context"
+	"strings"
+	"encoding/json"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// SecretProvider gets the raw HS256 secret (plain string or HmacKeys json)
+type SecretProvider interface {
+	GetSecret(ctx context.Context) (string, error)
+}
+
+// hmacRefresh controls the periodic reload of the HS256 keys
+type hmacRefresh struct {
+	mutex			sync.Mutex
+	secretProvider	SecretProvider
+	refreshInterval	time.Duration
+	loadedAt		time.Time
+}
+
+// About set the secret provider and load the HS256 keys
+func (w *WorkerService) SetSecretProvider(ctx context.Context, secretProvider SecretProvider) error {
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","SetSecretProvider").Send()
+
+	var refreshInterval time.Duration
+	if w.appServer.HmacSecret != nil {
+		refreshInterval = w.appServer.HmacSecret.RefreshInterval
+	}
+
+	w.hmacRefresh = &hmacRefresh{
+		secretProvider: secretProvider,
+		refreshInterval: refreshInterval,
+	}
+
+	return w.loadHmacKeys(ctx)
+}
+
+// About reload the HS256 keys when the refresh interval is reached
+// When the reload fails the current keys are kept
+func (w *WorkerService) refreshHmacKeys(ctx context.Context) {
+	if w.hmacRefresh == nil || w.hmacRefresh.refreshInterval <= 0 {
+		return
+	}
+
+	w.hmacRefresh.mutex.Lock()
+	stale := time.Since(w.hmacRefresh.loadedAt) > w.hmacRefresh.refreshInterval
+	w.hmacRefresh.mutex.Unlock()
+
+	if stale {
+		if err := w.loadHmacKeys(ctx); err != nil {
+			w.logger.Error().
+				Ctx(ctx).
+				Err(err).
+				Msg("erro refresh HS256 keys, the current keys are kept")
+		}
+	}
+}
+
+// About load the HS256 keys from the secret provider in the HS256 key set
+func (w *WorkerService) loadHmacKeys(ctx context.Context) error {
+	w.hmacRefresh.mutex.Lock()
+	defer w.hmacRefresh.mutex.Unlock()
+
+	// mark as loaded even on error, avoiding call the provider in every request
+	w.hmacRefresh.loadedAt = time.Now()
+
+	secret, err := w.hmacRefresh.secretProvider.GetSecret(ctx)
+	if err != nil {
+		return err
+	}
+
+	hmacKeys, err := parseHmacKeys(secret)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]interface{}, len(hmacKeys.Keys))
+	for kid, key := range hmacKeys.Keys {
+		keys[kid] = []byte(key)
+	}
+
+	w.keySets["HS256"].Replace(keys, hmacKeys.CurrentKid)
+
+	w.logger.Info().
+		Ctx(ctx).
+		Str("current_kid", hmacKeys.CurrentKid).
+		Int("keys", len(keys)).
+		Msg("HS256 keys loaded")
+
+	return nil
+}
+
+// About parse the secret, a json HmacKeys or a plain string (single key)
+func parseHmacKeys(secret string) (*model.HmacKeys, error) {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return nil, erro.ErrDecodeKey
+	}
+
+	if !strings.HasPrefix(secret, "{") {
+		return &model.HmacKeys{
+			Keys: map[string]string{"": secret},
+		}, nil
+	}
+
+	hmacKeys := model.HmacKeys{}
+	if err := json.Unmarshal([]byte(secret), &hmacKeys); err != nil {
+		return nil, erro.ErrUnmarshal
+	}
+	if len(hmacKeys.Keys) == 0 {
+		return nil, erro.ErrDecodeKey
+	}
+	if hmacKeys.CurrentKid != "" {
+		if _, ok := hmacKeys.Keys[hmacKeys.CurrentKid]; !ok {
+			return nil, erro.ErrKidNotFound
+		}
+	}
+
+	return &hmacKeys, nil
+}
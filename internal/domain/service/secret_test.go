@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// testSecretProvider returns the raw secret (plain string or HmacKeys json)
+type testSecretProvider string
+
+func (s testSecretProvider) GetSecret(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+func TestParseHmacKeys(t *testing.T) {
+	tests := []struct {
+		name		string
+		secret		string
+		want		*model.HmacKeys
+		wantErr		error
+	}{
+		{name: "plain secret", secret: " secret-01\n", want: &model.HmacKeys{Keys: map[string]string{"": "secret-01"}}},
+		{name: "json keys", secret: `{"current_kid":"v2","keys":{"v1":"secret-01","v2":"secret-02"}}`, want: &model.HmacKeys{CurrentKid: "v2", Keys: map[string]string{"v1": "secret-01", "v2": "secret-02"}}},
+		{name: "json keys without current kid", secret: `{"keys":{"v1":"secret-01"}}`, want: &model.HmacKeys{Keys: map[string]string{"v1": "secret-01"}}},
+		{name: "empty", secret: " ", wantErr: erro.ErrDecodeKey},
+		{name: "invalid json", secret: `{"keys":`, wantErr: erro.ErrUnmarshal},
+		{name: "json without keys", secret: `{"current_kid":"v1"}`, wantErr: erro.ErrDecodeKey},
+		{name: "current kid not in the keys", secret: `{"current_kid":"v3","keys":{"v1":"secret-01"}}`, wantErr: erro.ErrKidNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHmacKeys(tt.secret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseHmacKeys() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+			if got.CurrentKid != tt.want.CurrentKid || len(got.Keys) != len(tt.want.Keys) {
+				t.Fatalf("parseHmacKeys() = %+v, want %+v", got, tt.want)
+			}
+			for kid, key := range tt.want.Keys {
+				if got.Keys[kid] != key {
+					t.Fatalf("parseHmacKeys() key %q = %q, want %q", kid, got.Keys[kid], key)
+				}
+			}
+		})
+	}
+}
+
+func TestHmacKidSelection(t *testing.T) {
+	tests := []struct {
+		name		string
+		secret		string
+		key			string
+		kid			string
+		wantErr		error
+	}{
+		{name: "kid", secret: `{"current_kid":"v2","keys":{"v1":"secret-01","v2":"secret-02"}}`, key: "secret-01", kid: "v1"},
+		{name: "without kid uses the current kid", secret: `{"current_kid":"v2","keys":{"v1":"secret-01","v2":"secret-02"}}`, key: "secret-02"},
+		{name: "kid of another key", secret: `{"current_kid":"v2","keys":{"v1":"secret-01","v2":"secret-02"}}`, key: "secret-02", kid: "v1", wantErr: erro.ErrSignatureInvalid},
+		{name: "unknown kid", secret: `{"current_kid":"v2","keys":{"v1":"secret-01","v2":"secret-02"}}`, key: "secret-02", kid: "v3", wantErr: erro.ErrKidNotFound},
+		{name: "plain secret without kid", secret: "secret-01", key: "secret-01"},
+		{name: "plain secret with any kid", secret: "secret-01", key: "secret-01", kid: "v1"},
+		{name: "plain secret other key", secret: "secret-01", key: "secret-02", kid: "v1", wantErr: erro.ErrSignatureInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorkerService(t, &model.AppServer{
+				Application: &model.Application{AuthenticationModel: "HS256"},
+				TokenValidation: map[string]*model.TokenValidation{"HS256": {AllowedAlgorithms: []string{"HS256"}}},
+			})
+			if err := w.SetSecretProvider(context.Background(), testSecretProvider(tt.secret)); err != nil {
+				t.Fatalf("SetSecretProvider() error = %v", err)
+			}
+
+			token := signTestToken(t, jwt.SigningMethodHS256, []byte(tt.key), tt.kid, testClaims())
+			if _, err := w.TokenSignedValidation(context.Background(), token); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenSignedValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,15 +1,21 @@
 package service
 
 import (
-	"fmt"
+	"errors"
+	"slices"
+	"time"
 	"context"
 	"strings"
+	"crypto/rsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 
 	"github.com/rs/zerolog"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/aws/aws-lambda-go/events"
 
 	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/shared/cache"
 	"github.com/lambda-go-oauth2/internal/domain/model"
 
 	"go.opentelemetry.io/otel/trace"
@@ -17,70 +23,380 @@ import (
 	go_core_otel_trace "github.com/eliezerraj/go-core/v2/otel/trace"
 )
 
+// algorithmModels is the authentication model of each signing algorithm (mixed model)
+var algorithmModels = map[string]string{
+	"RS256": "RSA", "RS384": "RSA", "RS512": "RSA",
+	"PS256": "RSA", "PS384": "RSA", "PS512": "RSA",
+	"ES256": "ECDSA", "ES384": "ECDSA", "ES512": "ECDSA",
+	"EdDSA": "EDDSA",
+	"HS256": "HS256", "HS384": "HS256", "HS512": "HS256",
+}
+
 type WorkerService struct {
 	appServer 		*model.AppServer
 	logger 	  		*zerolog.Logger
 	tracerProvider 	*go_core_otel_trace.TracerProvider
-
-	TokenSignedValidation func(string, interface{}, *zerolog.Logger) (*model.JwtData, error)
+	keySets			map[string]*KeySet
+	revocationStore	RevocationStore
+	revocationCache	*cache.Cache[bool]
+	credentialScopeStore	CredentialScopeStore
+	credentialScopeCache	*cache.Cache[model.ScopeList]
+
+	hmacRefresh		*hmacRefresh
+	remoteKeys		*remoteKeys
+	trustedIssuers	map[string]*issuerTrust
+	introspector	Introspector
+	introspectionCache	*cache.Cache[*model.JwtData]
+	crlRefresh		*crlRefresh
+	ocspCheck		*ocspCheck
+	clientCertTrust	*clientCertTrust
+	scopePolicy		*scopePolicy
+
+	TokenSignedValidation func(context.Context, string) (*model.JwtData, error)
 }
 
 // ------------------------- RSA ------------------------------/
 // About check token RSA expired/signature and claims
-func tokenValidationRSA(bearerToken string, 
-						rsaPubKey interface{},
-						logger *zerolog.Logger)( *model.JwtData, error){
-	logger.Info().
+func (w *WorkerService) tokenValidationRSA(ctx context.Context, bearerToken string)( *model.JwtData, error){
+	w.logger.Info().
 		Str("func","tokenValidationRSA").Send()
 
+	return w.tokenValidationPublicKey(ctx, bearerToken, "RSA")
+}
+
+// ------------------------- ECDSA ------------------------------/
+// About check token ECDSA (ES256/ES384) expired/signature and claims
+func (w *WorkerService) tokenValidationECDSA(ctx context.Context, bearerToken string)( *model.JwtData, error){
+	w.logger.Info().
+		Str("func","tokenValidationECDSA").Send()
+
+	return w.tokenValidationPublicKey(ctx, bearerToken, "ECDSA")
+}
+
+// ------------------------- EdDSA ------------------------------/
+// About check token EdDSA (Ed25519) expired/signature and claims
+func (w *WorkerService) tokenValidationEdDSA(ctx context.Context, bearerToken string)( *model.JwtData, error){
+	w.logger.Info().
+		Str("func","tokenValidationEdDSA").Send()
+
+	return w.tokenValidationPublicKey(ctx, bearerToken, "EDDSA")
+}
+
+// ------------------------- MIXED ------------------------------/
+// About check token of any model allowed in the mixed allowlist (ex: RS256 and HS256 during a migration)
+// The model is choosen by the header alg, and the kid can not belong to a key set of another model
+func (w *WorkerService) tokenValidationMixed(ctx context.Context, bearerToken string)( *model.JwtData, error){
+	w.logger.Info().
+		Str("func","tokenValidationMixed").Send()
+
+	unverifiedToken, _, err := jwt.NewParser().ParseUnverified(bearerToken, &model.JwtData{})
+	if err != nil {
+		return nil, w.tokenValidationError(err)
+	}
+
+	// the alg must be in the mixed allowlist
+	if err := w.algorithmValidation(unverifiedToken, w.allowedAlgorithms("MIXED"), "MIXED"); err != nil {
+		return nil, err
+	}
+
+	authModel, ok := algorithmModels[unverifiedToken.Method.Alg()]
+	if !ok {
+		return nil, erro.ErrAlgorithmNotAllowed
+	}
+
+	if authModel == "HS256" {
+		w.refreshHmacKeys(ctx)
+	}
+
+	// a kid of another model with this alg is an algorithm confusion (ex: HS256 with the rsa kid)
+	if kid, _ := unverifiedToken.Header["kid"].(string); kid != "" && !w.keySets[authModel].Has(kid) {
+		for otherModel, keySet := range w.keySets {
+			if otherModel != authModel && keySet.Has(kid) {
+				w.logger.Warn().
+					Str("kid", kid).
+					Str("alg", unverifiedToken.Method.Alg()).
+					Str("kid_authentication_model", otherModel).
+					Msg("token kid belongs to another authentication model")
+				return nil, erro.ErrAlgorithmNotAllowed
+			}
+		}
+	}
+
+	return w.tokenValidationPublicKey(ctx, bearerToken, authModel)
+}
+
+// About check token signed by a key of the authentication model key set (RSA, ECDSA, EDDSA, HS256)
+// The key is choosen by the kid informed in the token header
+func (w *WorkerService) tokenValidationPublicKey(ctx context.Context, bearerToken string, authModel string)( *model.JwtData, error){
+	return w.tokenVerify(ctx, 
+						 bearerToken, 
+						 authModel, 
+						 w.keySets, 
+						 w.remoteKeys, 
+						 w.allowedAlgorithms(authModel))
+}
+
+// About verify the token signature with the key sets (and the remote jwks) informed
+// The token alg must be in the allowed algorithms, the time rules are the ones of the authentication model
+func (w *WorkerService) tokenVerify(ctx context.Context, 
+									bearerToken string, 
+									authModel string,
+									keySets map[string]*KeySet,
+									remote *remoteKeys,
+									allowedAlgorithms []string)( *model.JwtData, error){
+	if remote != nil {
+		remote.refreshIfExpired(ctx, w)
+	}
+
 	claims := &model.JwtData{}
 	tkn, err := jwt.ParseWithClaims(bearerToken, 
 								  claims, func(token *jwt.Token) (interface{}, error) {
-		return rsaPubKey, nil
-	})
+		if err := w.algorithmValidation(token, allowedAlgorithms, authModel); err != nil {
+			return nil, err
+		}
+		keySet, ok := keySets[authModel]
+		if !ok {
+			return nil, erro.ErrKidNotFound
+		}
 
-	if err != nil {
-		if err == jwt.ErrSignatureInvalid {
-			return nil, erro.ErrStatusUnauthorized
+		kid := tokenKid(token)
+		key, err := keySet.Get(kid)
+		// a unknown kid may be a key rotated in the remote jwks
+		if errors.Is(err, erro.ErrKidNotFound) && kid != "" && remote != nil && authModel != "HS256" {
+			if remote.refreshUnknownKid(ctx, w, kid) {
+				return keySet.Get(kid)
+			}
 		}
-		return nil, erro.ErrTokenExpired
+		return key, err
+	}, w.parserOptions(authModel)...)
+
+	if err != nil {
+		return nil, w.tokenValidationError(err)
+	}
+
+	if err := w.tokenTimeValidation(claims, authModel); err != nil {
+		return nil, err
 	}
 
 	if !tkn.Valid {
 		return nil, erro.ErrStatusUnauthorized
 	}
 
+	// the payload already verified above, with the claims that are not JwtData fields
+	rawClaims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(bearerToken, rawClaims); err != nil {
+		return nil, erro.ErrTokenMalformed
+	}
+	claims.RawClaims = rawClaims
+
+	w.scopeNormalization(claims)
+
 	return claims, nil
 }
 
 // -------------------------------H 256 ---------------
 // About check token HS256 expired/signature and claims
-func tokenValidationHS256(bearerToken string, 
-						  hs256Key interface{},
-						  logger *zerolog.Logger) ( *model.JwtData, error){
-
-	logger.Info().
+// The key is choosen by the kid informed in the token header, the keys are reloaded periodically
+func (w *WorkerService) tokenValidationHS256(ctx context.Context, bearerToken string) ( *model.JwtData, error){
+	w.logger.Info().
 		Str("func","TokenValidationHS256").Send()
 
-	claims := &model.JwtData{}
-	tkn, err := jwt.ParseWithClaims(bearerToken, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(fmt.Sprint(hs256Key)), nil
-	})
+	w.refreshHmacKeys(ctx)
 
-	if err != nil {
-		if err == jwt.ErrSignatureInvalid {
-			return nil, erro.ErrStatusUnauthorized
-		}
-		return nil, erro.ErrTokenExpired
+	return w.tokenValidationPublicKey(ctx, bearerToken, "HS256")
+}
+// ------------------------- Support ------------------------------/
+// About check if the token algorithm (header alg) is in the allowlist of the authentication model
+// It must be done before return the key, avoiding the alg confusion (ex: HS256 signed with the RSA public pem)
+func (w *WorkerService) algorithmValidation(token *jwt.Token, allowedAlgorithms []string, authModel string) error {
+	if token.Method == nil || !slices.Contains(allowedAlgorithms, token.Method.Alg()) {
+		w.logger.Warn().
+			Interface("alg", token.Header["alg"]).
+			Str("authentication_model", authModel).
+			Msg("token algorithm NOT ALLOWED")
+		return erro.ErrAlgorithmNotAllowed
 	}
 
-	if !tkn.Valid {
-		return nil, erro.ErrStatusUnauthorized
+	return nil
+}
+
+// About the allowed algorithms of the authentication model
+func (w *WorkerService) allowedAlgorithms(authModel string) []string {
+	if tokenValidation, ok := w.appServer.TokenValidation[authModel]; ok {
+		return tokenValidation.AllowedAlgorithms
 	}
+	return nil
+}
 
-	return claims, nil
+// About the jwt parser options of the authentication model (exp required, leeway and iat not in the future)
+func (w *WorkerService) parserOptions(authModel string) []jwt.ParserOption {
+	parserOptions := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+
+	if tokenValidation, ok := w.appServer.TokenValidation[authModel]; ok && tokenValidation.Leeway > 0 {
+		parserOptions = append(parserOptions, jwt.WithLeeway(tokenValidation.Leeway))
+	}
+
+	return parserOptions
 }
-// ------------------------- Support ------------------------------/
+
+// About check the iat/nbf presence, the token max age (now - iat) and max lifetime (exp - iat)
+func (w *WorkerService) tokenTimeValidation(claims *model.JwtData, authModel string) error {
+	tokenValidation, ok := w.appServer.TokenValidation[authModel]
+	if !ok {
+		return nil
+	}
+
+	needIssuedAt := tokenValidation.RequireIssuedAt || tokenValidation.MaxTokenAge > 0 || tokenValidation.MaxTokenLifetime > 0
+	if needIssuedAt && claims.IssuedAt == nil {
+		w.logger.Warn().
+			Str("authentication_model", authModel).
+			Msg("token iat claim MISSING")
+		return erro.ErrTokenClaimMissing
+	}
+
+	if tokenValidation.RequireNotBefore && claims.NotBefore == nil {
+		w.logger.Warn().
+			Str("authentication_model", authModel).
+			Msg("token nbf claim MISSING")
+		return erro.ErrTokenClaimMissing
+	}
+
+	if tokenValidation.MaxTokenAge > 0 && time.Since(claims.IssuedAt.Time) > tokenValidation.MaxTokenAge + tokenValidation.Leeway {
+		w.logger.Warn().
+			Str("authentication_model", authModel).
+			Time("iat", claims.IssuedAt.Time).
+			Msg("token max age EXCEEDED")
+		return erro.ErrTokenTooOld
+	}
+
+	if tokenValidation.MaxTokenLifetime > 0 && claims.ExpiresAt.Sub(claims.IssuedAt.Time) > tokenValidation.MaxTokenLifetime {
+		w.logger.Warn().
+			Str("authentication_model", authModel).
+			Time("iat", claims.IssuedAt.Time).
+			Time("exp", claims.ExpiresAt.Time).
+			Msg("token max lifetime EXCEEDED")
+		return erro.ErrTokenLifetime
+	}
+
+	return nil
+}
+
+// About map the jwt parse error in the erro set
+// The order matters, a keyfunc error (ex: kid not found) is wrapped by jwt.ErrTokenUnverifiable
+func (w *WorkerService) tokenValidationError(err error) error {
+	var validationErr error
+
+	switch {
+	case errors.Is(err, erro.ErrAlgorithmNotAllowed):
+		validationErr = erro.ErrAlgorithmNotAllowed
+	case errors.Is(err, erro.ErrKidNotFound):
+		validationErr = erro.ErrKidNotFound
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		validationErr = erro.ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), 
+		 errors.Is(err, jwt.ErrSignatureInvalid),
+		 errors.Is(err, jwt.ErrECDSAVerification),
+		 errors.Is(err, jwt.ErrEd25519Verification):
+		validationErr = erro.ErrSignatureInvalid
+	case errors.Is(err, jwt.ErrTokenUnverifiable):
+		validationErr = erro.ErrTokenUnverifiable
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		validationErr = erro.ErrTokenClaimMissing
+	case errors.Is(err, jwt.ErrTokenExpired):
+		validationErr = erro.ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		validationErr = erro.ErrTokenNotValidYet
+	case errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		validationErr = erro.ErrTokenUsedBeforeIssued
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		validationErr = erro.ErrTokenIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		validationErr = erro.ErrTokenAudience
+	case errors.Is(err, jwt.ErrTokenInvalidClaims),
+		 errors.Is(err, jwt.ErrTokenInvalidSubject),
+		 errors.Is(err, jwt.ErrTokenInvalidId),
+		 errors.Is(err, jwt.ErrInvalidType):
+		validationErr = erro.ErrTokenInvalidClaims
+	default:
+		validationErr = erro.ErrStatusUnauthorized
+	}
+
+	w.logger.Warn().
+		Err(err).
+		Str("validation_error", validationErr.Error()).
+		Msg("token validation FAILED")
+
+	return validationErr
+}
+
+// About get the kid from token header (JOSE), the kid claim of the payload is not used to choose the key
+func tokenKid(token *jwt.Token) string {
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+// About create the key sets of each authentication model with the local public keys and the jwks keys (if loaded)
+// The HS256 key set is loaded by the secret provider
+func newKeySets(rsaKey *model.RsaKey, logger *zerolog.Logger) map[string]*KeySet {
+	keySets := map[string]*KeySet{
+		"HS256": NewKeySet(""),
+	}
+	if rsaKey == nil {
+		return keySets
+	}
+
+	// authentication model => jwk kty and local public key
+	publicKeys := []struct{
+		authModel	string
+		kty			string
+		key			interface{}
+	}{
+		{"RSA", "RSA", rsaKey.RsaPublic},
+		{"ECDSA", "EC", rsaKey.EcPublic},
+		{"EDDSA", "OKP", rsaKey.EdPublic},
+	}
+
+	for _, publicKey := range publicKeys {
+		keySet := NewKeySet(rsaKey.Kid)
+
+		switch key := publicKey.key.(type) {
+		case *rsa.PublicKey:
+			if key != nil {
+				keySet.Add(rsaKey.Kid, key)
+			}
+		case *ecdsa.PublicKey:
+			if key != nil {
+				keySet.Add(rsaKey.Kid, key)
+			}
+		case ed25519.PublicKey:
+			if len(key) > 0 {
+				keySet.Add(rsaKey.Kid, key)
+			}
+		}
+
+		if rsaKey.Jwks != nil {
+			if err := keySet.LoadJwks(rsaKey.Jwks, publicKey.kty, logger); err != nil {
+				logger.Error().
+					Err(err).
+					Str("authentication_model", publicKey.authModel).
+					Msg("erro load jwks, only the local public key will be used")
+			}
+		}
+
+		logger.Info().
+			Str("authentication_model", publicKey.authModel).
+			Int("keys", keySet.Len()).
+			Msg("key set loaded")
+
+		keySets[publicKey.authModel] = keySet
+	}
+
+	return keySets
+}
+
 // About new worker service
 func NewWorkerService(appServer *model.AppServer,
 					  appLogger *zerolog.Logger,
@@ -92,20 +408,35 @@ func NewWorkerService(appServer *model.AppServer,
 	logger.Info().
 		Str("func","NewWorkerService").Send()
 
-	var tokenSignedValidation func(string, interface{}, *zerolog.Logger) (*model.JwtData, error)
-
-	if appServer.Application.AuthenticationModel == "RSA" {
-		tokenSignedValidation = tokenValidationRSA
-	} else {
-		tokenSignedValidation = tokenValidationHS256
-	}
-
-	return &WorkerService{
+	workerService := &WorkerService{
 		appServer: appServer,
 		logger: &logger,
 		tracerProvider: tracerProvider,
-		TokenSignedValidation: tokenSignedValidation,
+		keySets: newKeySets(appServer.RsaKey, &logger),
 	}
+
+	switch appServer.Application.AuthenticationModel {
+	case "RSA":
+		workerService.TokenSignedValidation = workerService.tokenValidationRSA
+	case "ECDSA":
+		workerService.TokenSignedValidation = workerService.tokenValidationECDSA
+	case "EDDSA":
+		workerService.TokenSignedValidation = workerService.tokenValidationEdDSA
+	case "MIXED":
+		workerService.TokenSignedValidation = workerService.tokenValidationMixed
+	case "HS256":
+		workerService.TokenSignedValidation = workerService.tokenValidationHS256
+	default:
+		// the config rejects a unknown model, without a model all the tokens are denied
+		logger.Error().
+			Str("authentication_model", appServer.Application.AuthenticationModel).
+			Msg("authentication model NOT SUPPORTED")
+		workerService.TokenSignedValidation = func(ctx context.Context, bearerToken string) (*model.JwtData, error) {
+			return nil, erro.ErrAlgorithmNotAllowed
+		}
+	}
+
+	return workerService
 }
 
 // About Generate Policy
@@ -136,7 +467,35 @@ func(w *WorkerService) GeneratePolicyFromClaims(ctx context.Context,
 	// InsertDataAuthorizationContext
 	authResponse.Context = make(map[string]interface{})
 	authResponse.Context["authMessage"] = policyData.Message
+	if policyData.Reason != "" {
+		authResponse.Context["authReason"] = policyData.Reason
+	}
 	authResponse.Context["tenant_id"] = "NO-TENANT"
+	if claims != nil && claims.Tenant != "" {
+		authResponse.Context["tenant_id"] = claims.Tenant
+	}
+
+	// the validated mTLS client certificate
+	if policyData.ClientCert != nil {
+		authResponse.Context["client_cert_subject_dn"] = policyData.ClientCert.SubjectDN
+		authResponse.Context["client_cert_issuer_dn"] = policyData.ClientCert.IssuerDN
+		authResponse.Context["client_cert_serial_number"] = policyData.ClientCert.SerialNumber
+		if len(policyData.ClientCert.DnsNames) > 0 {
+			authResponse.Context["client_cert_san_dns"] = strings.Join(policyData.ClientCert.DnsNames, ",")
+		}
+		if len(policyData.ClientCert.EmailAddresses) > 0 {
+			authResponse.Context["client_cert_san_email"] = strings.Join(policyData.ClientCert.EmailAddresses, ",")
+		}
+		if len(policyData.ClientCert.IpAddresses) > 0 {
+			authResponse.Context["client_cert_san_ip"] = strings.Join(policyData.ClientCert.IpAddresses, ",")
+		}
+		if len(policyData.ClientCert.Uris) > 0 {
+			authResponse.Context["client_cert_san_uri"] = strings.Join(policyData.ClientCert.Uris, ",")
+		}
+		if policyData.ClientCert.SpiffeId != "" {
+			authResponse.Context["spiffe_id"] = policyData.ClientCert.SpiffeId
+		}
+	}
 
 	if claims != nil {
 		// check insert jwt-id
@@ -167,8 +526,93 @@ func(w *WorkerService) GeneratePolicyFromClaims(ctx context.Context,
 	return authResponse
 }
 
-// About Scope validation (naive only for test)
-func(w *WorkerService) ScopeValidation (ctx context.Context, claims model.JwtData, arn string) bool{
+// About parse the method arn
+// Ex: arn:aws:execute-api:us-east-2:908671954593:k0ng1bdik7/qa/GET/account/info
+func parseMethodArn(arn string) (*model.MethodArn, error) {
+	arnSlice := strings.SplitN(arn, ":", 6)
+	if len(arnSlice) != 6 {
+		return nil, erro.ErrArnMalFormad
+	}
+
+	resource := strings.SplitN(arnSlice[5], "/", 4)
+	if len(resource) != 4 {
+		return nil, erro.ErrArnMalFormad
+	}
+
+	return &model.MethodArn{
+		Region:		arnSlice[3],
+		Account:	arnSlice[4],
+		ApiId:		resource[0],
+		Stage:		resource[1],
+		Method:		resource[2],
+		Path:		resource[3],
+	}, nil
+}
+
+// About Claims validation (issuer, audience and token_use)
+// The audience is choosen by the api id of the method arn, the * api id is used as default
+func(w *WorkerService) ClaimsValidation(ctx context.Context, claims model.JwtData, arn string) error{
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","ClaimsValidation").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.ClaimsValidation", trace.SpanKindServer)
+	defer span.End()
+
+	claimValidation := w.appServer.ClaimValidation
+	if claimValidation == nil {
+		return nil
+	}
+
+	// issuer
+	if len(claimValidation.Issuers) > 0 && !slices.Contains(claimValidation.Issuers, claims.ISS) {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("iss", claims.ISS).
+			Msg("token issuer NOT ALLOWED")
+		return erro.ErrTokenIssuer
+	}
+
+	// token_use
+	if len(claimValidation.TokenUse) > 0 && !slices.Contains(claimValidation.TokenUse, claims.TokenUse) {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("token_use", claims.TokenUse).
+			Msg("token_use NOT ALLOWED")
+		return erro.ErrTokenUse
+	}
+
+	// audience
+	if len(claimValidation.Audiences) > 0 {
+		methodArn, err := parseMethodArn(arn)
+		if err != nil {
+			return err
+		}
+
+		audiences, ok := claimValidation.Audiences[methodArn.ApiId]
+		if !ok {
+			audiences, ok = claimValidation.Audiences["*"]
+		}
+
+		if ok && !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+			return slices.Contains(audiences, aud)
+		}) {
+			w.logger.Warn().
+				Ctx(ctx).
+				Strs("aud", claims.Audience).
+				Str("api_id", methodArn.ApiId).
+				Msg("token audience NOT ALLOWED")
+			return erro.ErrTokenAudience
+		}
+	}
+
+	return nil
+}
+
+// About Scope validation, by the scope policy (route rules) when informed, otherwise by the default rules
+// The request data is seen by the route conditions of the scope policy
+func(w *WorkerService) ScopeValidation (ctx context.Context, claims model.JwtData, arn string, requestData *model.RequestData) bool{
 	w.logger.Info().
 		Ctx(ctx).
 		Str("func","ScopeValidation").Send()
@@ -179,28 +623,59 @@ func(w *WorkerService) ScopeValidation (ctx context.Context, claims model.JwtDat
 
 	// valid the arn
 	res_arn := strings.SplitN(arn, "/", 4)
+	if len(res_arn) != 4 {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("arn", arn).
+			Msg("method arn MALFORMED")
+		return false
+	}
 	method := res_arn[2]
 	path := res_arn[3]
 
-		// Valid the scope in a naive way
+	// the SPIFFE ID principal (client certificate) is authorized by the allowlist
+	if claims.SpiffeId != "" {
+		return w.spiffeValidation(ctx, claims.SpiffeId, path)
+	}
+
+	policy := w.scopePolicy
+	if policy == nil {
+		policy = defaultScopePolicy
+	}
+
+	// the token scopes are expanded once, the superscopes are allowed in all the paths (but the route conditions still apply)
+	grantedScopes := policy.expandScopes(claims.Scope)
+	superscope := slices.ContainsFunc(policy.superscopes, grantedScopes.has)
+
+	if len(policy.routes) > 0 {
+		return w.scopePolicyValidation(ctx, grantedScopes, superscope, claims, arn, requestData, method, path)
+	}
+
+	if superscope {
+		w.logger.Debug().
+			Ctx(ctx).
+			Msg("++++++++++ TRUE SUPERSCOPE ++++++++++++++++++")
+		return true
+	}
+
+	return w.scopeValidationDefault(ctx, grantedScopes, method, path)
+}
+
+// About the default scope rules (no route rules), scope path:method (read, write, update, delete, * all) or just path (all the methods)
+func(w *WorkerService) scopeValidationDefault(ctx context.Context, grantedScopes scopeSet, method string, path string) bool{
+	// Valid the scope in a naive way
 	var pathScope, methodScope string
-	for _, scopeListItem := range claims.Scope {
+	for scopeListItem := range grantedScopes {
 		// Split ex: versiom.read in 2 parts
 		scopeSlice := strings.Split(scopeListItem, ":")
 		pathScope = scopeSlice[0]
 		
 		// In this case when just method informed it means the all methods are allowed (ANY)
-		// Ex: path (info) or (admin)
+		// Ex: path (info)
 		// if lenght is 1, means only the path was given
 		if len(scopeSlice) == 1 {
-			if pathScope == "admin" {
-				w.logger.Debug().
-					Ctx(ctx).
-					Msg("++++++++++ TRUE ADMIN ++++++++++++++++++")
-				return true
-			}
-			// if the path is equal scope, ex: info (informed) is equal info (scope)
-			if strings.Contains(path, scopeSlice[0]) {
+			// if the path has the scope segments, ex: account/info (informed) has info (scope), but not account/information
+			if pathHasSegments(path, pathScope) {
 				w.logger.Debug().
 					Ctx(ctx).
 					Msg("++++++++++ NO ADMIN BUT SCOPE ANY ++++++++++++++++++")
@@ -216,10 +691,10 @@ func(w *WorkerService) ScopeValidation (ctx context.Context, claims model.JwtDat
 
 			methodScope = scopeSlice[1]
 
-			if pathScope == path {
+			if strings.Trim(pathScope, "/") == strings.Trim(path, "/") {
 				w.logger.Debug().
 				    Msg("PASS - Paths equals !!!")
-				if method == "ANY" {
+				if method == "ANY" || methodScope == "*" {
 					w.logger.Debug().
 					    Msg("ALLOWED - method ANY!!!")
 					return true
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"time"
+	"fmt"
+	"errors"
+	"context"
+	"testing"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/ed25519"
+	"encoding/pem"
+
+	"github.com/rs/zerolog"
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+
+	go_core_otel_trace "github.com/eliezerraj/go-core/v2/otel/trace"
+)
+
+// newTestWorkerService creates a worker service with a noop tracer and logger
+func newTestWorkerService(t *testing.T, appServer *model.AppServer) *WorkerService {
+	t.Helper()
+
+	if appServer.Application == nil {
+		appServer.Application = &model.Application{AuthenticationModel: "RSA"}
+	}
+
+	logger := zerolog.Nop()
+	tracerProvider := &go_core_otel_trace.TracerProvider{
+		Tracer: noop.NewTracerProvider().Tracer("test"),
+	}
+
+	return NewWorkerService(appServer, &logger, tracerProvider)
+}
+
+func newTestRsaKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// testClaims are the claims of a token valid for one hour
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"username": "user-01",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// signTestToken signs the claims with the kid informed in the header (none when empty)
+func signTestToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestAlgorithmValidation(t *testing.T) {
+	rsaKey := newTestRsaKey(t)
+
+	publicDer, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})
+
+	w := newTestWorkerService(t, &model.AppServer{
+		RsaKey: &model.RsaKey{Kid: "rsa-01", RsaPublic: &rsaKey.PublicKey},
+		TokenValidation: map[string]*model.TokenValidation{"RSA": {AllowedAlgorithms: []string{"RS256"}}},
+	})
+
+	tests := []struct {
+		name		string
+		token		string
+		wantErr		error
+	}{
+		{name: "allowed", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-01", testClaims())},
+		{name: "HS256 signed with the rsa public pem", token: signTestToken(t, jwt.SigningMethodHS256, publicPem, "rsa-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+		{name: "outside the allowlist", token: signTestToken(t, jwt.SigningMethodRS512, rsaKey, "rsa-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+		{name: "none", token: signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "rsa-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := w.TokenSignedValidation(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenSignedValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEcdsaEdDSAValidation(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherEcKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherEdKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name		string
+		authModel	string
+		publicKey	interface{}
+		token		string
+		wantErr		error
+	}{
+		{name: "ES256", authModel: "ECDSA", publicKey: &ecKey.PublicKey, token: signTestToken(t, jwt.SigningMethodES256, ecKey, "key-01", testClaims())},
+		{name: "ES384", authModel: "ECDSA", publicKey: &ec384Key.PublicKey, token: signTestToken(t, jwt.SigningMethodES384, ec384Key, "key-01", testClaims())},
+		{name: "ES256 other key", authModel: "ECDSA", publicKey: &ecKey.PublicKey, token: signTestToken(t, jwt.SigningMethodES256, otherEcKey, "key-01", testClaims()), wantErr: erro.ErrSignatureInvalid},
+		{name: "ES512 outside the allowlist", authModel: "ECDSA", publicKey: &ec521Key.PublicKey, token: signTestToken(t, jwt.SigningMethodES512, ec521Key, "key-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+		{name: "EdDSA", authModel: "EDDSA", publicKey: edPublic, token: signTestToken(t, jwt.SigningMethodEdDSA, edKey, "key-01", testClaims())},
+		{name: "EdDSA other key", authModel: "EDDSA", publicKey: edPublic, token: signTestToken(t, jwt.SigningMethodEdDSA, otherEdKey, "key-01", testClaims()), wantErr: erro.ErrSignatureInvalid},
+		{name: "ES256 in the EDDSA model", authModel: "EDDSA", publicKey: edPublic, token: signTestToken(t, jwt.SigningMethodES256, ecKey, "key-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsaKey := &model.RsaKey{Kid: "key-01"}
+			switch publicKey := tt.publicKey.(type) {
+			case *ecdsa.PublicKey:
+				rsaKey.EcPublic = publicKey
+			case ed25519.PublicKey:
+				rsaKey.EdPublic = publicKey
+			}
+
+			w := newTestWorkerService(t, &model.AppServer{
+				Application: &model.Application{AuthenticationModel: tt.authModel},
+				RsaKey: rsaKey,
+				TokenValidation: map[string]*model.TokenValidation{
+					"ECDSA": {AllowedAlgorithms: []string{"ES256", "ES384"}},
+					"EDDSA": {AllowedAlgorithms: []string{"EdDSA"}},
+				},
+			})
+
+			_, err := w.TokenSignedValidation(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenSignedValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRsaPssValidation(t *testing.T) {
+	rsaKey := newTestRsaKey(t)
+	otherRsaKey := newTestRsaKey(t)
+
+	tests := []struct {
+		name		string
+		allowed		[]string
+		token		string
+		wantErr		error
+	}{
+		{name: "PS256 with the rsa key", allowed: []string{"RS256", "PS256"}, token: signTestToken(t, jwt.SigningMethodPS256, rsaKey, "rsa-01", testClaims())},
+		{name: "RS256 during the migration", allowed: []string{"RS256", "PS256"}, token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-01", testClaims())},
+		{name: "PS256 not allowed", allowed: []string{"RS256"}, token: signTestToken(t, jwt.SigningMethodPS256, rsaKey, "rsa-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+		{name: "PS384 outside the allowlist", allowed: []string{"PS256"}, token: signTestToken(t, jwt.SigningMethodPS384, rsaKey, "rsa-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+		{name: "PS256 other key", allowed: []string{"PS256"}, token: signTestToken(t, jwt.SigningMethodPS256, otherRsaKey, "rsa-01", testClaims()), wantErr: erro.ErrSignatureInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorkerService(t, &model.AppServer{
+				RsaKey: &model.RsaKey{Kid: "rsa-01", RsaPublic: &rsaKey.PublicKey},
+				TokenValidation: map[string]*model.TokenValidation{"RSA": {AllowedAlgorithms: tt.allowed}},
+			})
+
+			_, err := w.TokenSignedValidation(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenSignedValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClaimsValidation(t *testing.T) {
+	claimValidation := &model.ClaimValidation{
+		Issuers: []string{"lambda-go-oauth2"},
+		TokenUse: []string{"access"},
+		Audiences: map[string][]string{
+			"api01": {"api-account"},
+			"*": {"api-default"},
+		},
+	}
+
+	tests := []struct {
+		name			string
+		claimValidation	*model.ClaimValidation
+		iss				string
+		tokenUse		string
+		aud				[]string
+		arn				string
+		wantErr			error
+	}{
+		{name: "not configured", iss: "other", arn: "arn:aws:execute-api:us-east-2:111111111111:api01/qa/GET/account"},
+		{name: "valid", claimValidation: claimValidation, iss: "lambda-go-oauth2", tokenUse: "access", aud: []string{"api-account"}, arn: "arn:aws:execute-api:us-east-2:111111111111:api01/qa/GET/account"},
+		{name: "issuer not allowed", claimValidation: claimValidation, iss: "other", tokenUse: "access", aud: []string{"api-account"}, arn: "arn:aws:execute-api:us-east-2:111111111111:api01/qa/GET/account", wantErr: erro.ErrTokenIssuer},
+		{name: "id token", claimValidation: claimValidation, iss: "lambda-go-oauth2", tokenUse: "id", aud: []string{"api-account"}, arn: "arn:aws:execute-api:us-east-2:111111111111:api01/qa/GET/account", wantErr: erro.ErrTokenUse},
+		{name: "audience of another api", claimValidation: claimValidation, iss: "lambda-go-oauth2", tokenUse: "access", aud: []string{"api-default"}, arn: "arn:aws:execute-api:us-east-2:111111111111:api01/qa/GET/account", wantErr: erro.ErrTokenAudience},
+		{name: "default audience", claimValidation: claimValidation, iss: "lambda-go-oauth2", tokenUse: "access", aud: []string{"api-other", "api-default"}, arn: "arn:aws:execute-api:us-east-2:111111111111:api02/qa/GET/account"},
+		{name: "audience missing", claimValidation: claimValidation, iss: "lambda-go-oauth2", tokenUse: "access", arn: "arn:aws:execute-api:us-east-2:111111111111:api02/qa/GET/account", wantErr: erro.ErrTokenAudience},
+		{name: "malformed arn", claimValidation: claimValidation, iss: "lambda-go-oauth2", tokenUse: "access", aud: []string{"api-account"}, arn: "api01/qa/GET/account", wantErr: erro.ErrArnMalFormad},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorkerService(t, &model.AppServer{ClaimValidation: tt.claimValidation})
+
+			claims := model.JwtData{ISS: tt.iss, TokenUse: tt.tokenUse}
+			claims.Audience = tt.aud
+
+			if err := w.ClaimsValidation(context.Background(), claims, tt.arn); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClaimsValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenValidationError(t *testing.T) {
+	w := newTestWorkerService(t, &model.AppServer{})
+
+	tests := []struct {
+		name		string
+		err			error
+		want		error
+	}{
+		{name: "keyfunc alg not allowed", err: fmt.Errorf("%w: %w", jwt.ErrTokenUnverifiable, erro.ErrAlgorithmNotAllowed), want: erro.ErrAlgorithmNotAllowed},
+		{name: "keyfunc kid not found", err: fmt.Errorf("%w: %w", jwt.ErrTokenUnverifiable, erro.ErrKidNotFound), want: erro.ErrKidNotFound},
+		{name: "unverifiable", err: jwt.ErrTokenUnverifiable, want: erro.ErrTokenUnverifiable},
+		{name: "malformed", err: fmt.Errorf("%w: %w", jwt.ErrTokenMalformed, errors.New("illegal base64")), want: erro.ErrTokenMalformed},
+		{name: "signature", err: fmt.Errorf("%w: %w", jwt.ErrTokenSignatureInvalid, rsa.ErrVerification), want: erro.ErrSignatureInvalid},
+		{name: "ecdsa signature", err: fmt.Errorf("%w: %w", jwt.ErrTokenSignatureInvalid, jwt.ErrECDSAVerification), want: erro.ErrSignatureInvalid},
+		{name: "exp missing", err: fmt.Errorf("%w: %w", jwt.ErrTokenInvalidClaims, jwt.ErrTokenRequiredClaimMissing), want: erro.ErrTokenClaimMissing},
+		{name: "expired", err: fmt.Errorf("%w: %w", jwt.ErrTokenInvalidClaims, jwt.ErrTokenExpired), want: erro.ErrTokenExpired},
+		{name: "not valid yet", err: fmt.Errorf("%w: %w", jwt.ErrTokenInvalidClaims, jwt.ErrTokenNotValidYet), want: erro.ErrTokenNotValidYet},
+		{name: "used before issued", err: fmt.Errorf("%w: %w", jwt.ErrTokenInvalidClaims, jwt.ErrTokenUsedBeforeIssued), want: erro.ErrTokenUsedBeforeIssued},
+		{name: "issuer", err: fmt.Errorf("%w: %w", jwt.ErrTokenInvalidClaims, jwt.ErrTokenInvalidIssuer), want: erro.ErrTokenIssuer},
+		{name: "audience", err: fmt.Errorf("%w: %w", jwt.ErrTokenInvalidClaims, jwt.ErrTokenInvalidAudience), want: erro.ErrTokenAudience},
+		{name: "invalid claims", err: jwt.ErrTokenInvalidClaims, want: erro.ErrTokenInvalidClaims},
+		{name: "unknown", err: errors.New("unknown"), want: erro.ErrStatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.tokenValidationError(tt.err); got != tt.want {
+				t.Fatalf("tokenValidationError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenSignedValidationError(t *testing.T) {
+	rsaKey := newTestRsaKey(t)
+	otherRsaKey := newTestRsaKey(t)
+
+	w := newTestWorkerService(t, &model.AppServer{
+		RsaKey: &model.RsaKey{Kid: "rsa-01", RsaPublic: &rsaKey.PublicKey},
+		TokenValidation: map[string]*model.TokenValidation{"RSA": {AllowedAlgorithms: []string{"RS256"}}},
+	})
+
+	expired := testClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	withoutExp := testClaims()
+	delete(withoutExp, "exp")
+
+	tests := []struct {
+		name		string
+		token		string
+		wantErr		error
+	}{
+		{name: "malformed", token: "a.b.c", wantErr: erro.ErrTokenMalformed},
+		{name: "signature", token: signTestToken(t, jwt.SigningMethodRS256, otherRsaKey, "rsa-01", testClaims()), wantErr: erro.ErrSignatureInvalid},
+		{name: "expired", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-01", expired), wantErr: erro.ErrTokenExpired},
+		{name: "exp missing", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-01", withoutExp), wantErr: erro.ErrTokenClaimMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := w.TokenSignedValidation(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenSignedValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenTimeValidation(t *testing.T) {
+	rsaKey := newTestRsaKey(t)
+	now := time.Now()
+
+	// claims returns the test claims with the time claims informed (offset from now)
+	claims := func(timeClaims map[string]time.Duration) jwt.MapClaims {
+		mapClaims := testClaims()
+		for name, offset := range timeClaims {
+			mapClaims[name] = now.Add(offset).Unix()
+		}
+		return mapClaims
+	}
+
+	tests := []struct {
+		name			string
+		validation		model.TokenValidation
+		claims			jwt.MapClaims
+		wantErr			error
+	}{
+		{name: "expired", claims: claims(map[string]time.Duration{"exp": -10 * time.Second}), wantErr: erro.ErrTokenExpired},
+		{name: "expired in the leeway", validation: model.TokenValidation{Leeway: 30 * time.Second}, claims: claims(map[string]time.Duration{"exp": -10 * time.Second})},
+		{name: "nbf in the future", claims: claims(map[string]time.Duration{"nbf": 10 * time.Second}), wantErr: erro.ErrTokenNotValidYet},
+		{name: "nbf in the leeway", validation: model.TokenValidation{Leeway: 30 * time.Second}, claims: claims(map[string]time.Duration{"nbf": 10 * time.Second})},
+		{name: "iat in the future", claims: claims(map[string]time.Duration{"iat": time.Minute}), wantErr: erro.ErrTokenUsedBeforeIssued},
+		{name: "iat required", validation: model.TokenValidation{RequireIssuedAt: true}, claims: claims(nil), wantErr: erro.ErrTokenClaimMissing},
+		{name: "nbf required", validation: model.TokenValidation{RequireNotBefore: true}, claims: claims(map[string]time.Duration{"iat": 0}), wantErr: erro.ErrTokenClaimMissing},
+		{name: "max age requires iat", validation: model.TokenValidation{MaxTokenAge: time.Hour}, claims: claims(nil), wantErr: erro.ErrTokenClaimMissing},
+		{name: "max age", validation: model.TokenValidation{MaxTokenAge: time.Hour}, claims: claims(map[string]time.Duration{"iat": -30 * time.Minute})},
+		{name: "max age exceeded", validation: model.TokenValidation{MaxTokenAge: time.Hour}, claims: claims(map[string]time.Duration{"iat": -2 * time.Hour}), wantErr: erro.ErrTokenTooOld},
+		{name: "max lifetime exceeded", validation: model.TokenValidation{MaxTokenLifetime: time.Hour}, claims: claims(map[string]time.Duration{"iat": 0, "exp": 2 * time.Hour}), wantErr: erro.ErrTokenLifetime},
+		{name: "max lifetime", validation: model.TokenValidation{MaxTokenLifetime: time.Hour}, claims: claims(map[string]time.Duration{"iat": 0, "exp": 30 * time.Minute})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := tt.validation
+			validation.AllowedAlgorithms = []string{"RS256"}
+
+			w := newTestWorkerService(t, &model.AppServer{
+				RsaKey: &model.RsaKey{Kid: "rsa-01", RsaPublic: &rsaKey.PublicKey},
+				TokenValidation: map[string]*model.TokenValidation{"RSA": &validation},
+			})
+
+			_, err := w.TokenSignedValidation(context.Background(), signTestToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-01", tt.claims))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenSignedValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnsupportedAuthenticationModel(t *testing.T) {
+	w := newTestWorkerService(t, &model.AppServer{
+		Application: &model.Application{AuthenticationModel: "HSA"},
+		TokenValidation: map[string]*model.TokenValidation{"HS256": {AllowedAlgorithms: []string{"HS256"}}},
+	})
+
+	token := signTestToken(t, jwt.SigningMethodHS256, []byte("secret-01"), "", testClaims())
+	if _, err := w.TokenSignedValidation(context.Background(), token); !errors.Is(err, erro.ErrAlgorithmNotAllowed) {
+		t.Fatalf("TokenSignedValidation() error = %v, want %v", err, erro.ErrAlgorithmNotAllowed)
+	}
+}
+
+func TestMixedValidation(t *testing.T) {
+	rsaKey := newTestRsaKey(t)
+
+	w := newTestWorkerService(t, &model.AppServer{
+		Application: &model.Application{AuthenticationModel: "MIXED"},
+		RsaKey: &model.RsaKey{Kid: "rsa-01", RsaPublic: &rsaKey.PublicKey},
+		TokenValidation: map[string]*model.TokenValidation{
+			"RSA": {AllowedAlgorithms: []string{"RS256"}},
+			"HS256": {AllowedAlgorithms: []string{"HS256"}},
+			"MIXED": {AllowedAlgorithms: []string{"RS256", "HS256"}},
+		},
+	})
+	if err := w.SetSecretProvider(context.Background(), testSecretProvider(`{"current_kid":"hs-01","keys":{"hs-01":"secret-01"}}`)); err != nil {
+		t.Fatalf("SetSecretProvider() error = %v", err)
+	}
+
+	tests := []struct {
+		name		string
+		token		string
+		wantErr		error
+	}{
+		{name: "RS256", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-01", testClaims())},
+		{name: "HS256", token: signTestToken(t, jwt.SigningMethodHS256, []byte("secret-01"), "hs-01", testClaims())},
+		{name: "HS256 with the rsa kid", token: signTestToken(t, jwt.SigningMethodHS256, []byte("secret-01"), "rsa-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+		{name: "RS256 with the hs kid", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, "hs-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+		{name: "outside the mixed allowlist", token: signTestToken(t, jwt.SigningMethodHS512, []byte("secret-01"), "hs-01", testClaims()), wantErr: erro.ErrAlgorithmNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := w.TokenSignedValidation(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenSignedValidation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"regexp"
+	"context"
+	"strings"
+	"crypto/x509"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spiffe id charset (SPIFFE ID spec): trust domain [a-z0-9.-_] and path segments [a-zA-Z0-9.-_]
+var (
+	spiffeTrustDomain	= regexp.MustCompile(`^[a-z0-9._-]+$`)
+	spiffePathSegment	= regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+)
+
+// About check if the service-to-service authorization by SPIFFE ID is enabled
+func (w *WorkerService) SpiffeEnabled() bool {
+	return w.appServer.SpiffeAuthorization != nil && w.appServer.SpiffeAuthorization.Enabled
+}
+
+// About extract the SPIFFE ID of the mTLS client certificate as the principal (no jwt)
+// The claims have only the principal, the route is authorized by the SPIFFE allowlist
+func (w *WorkerService) SpiffePrincipal(ctx context.Context, certX509 *x509.Certificate) (*model.JwtData, error) {
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","SpiffePrincipal").Send()
+
+	// trace
+	ctx, span := w.tracerProvider.SpanCtx(ctx, "service.SpiffePrincipal", trace.SpanKindServer)
+	defer span.End()
+
+	if certX509 == nil {
+		w.logger.Warn().
+			Ctx(ctx).
+			Msg("client certificate NOT INFORMED, spiffe id not found")
+		return nil, erro.ErrSpiffeId
+	}
+
+	spiffeId, err := spiffeIdFromCert(certX509)
+	if err != nil {
+		w.logger.Warn().
+			Ctx(ctx).
+			Str("subject", certX509.Subject.String()).
+			Msg("client certificate spiffe id INVALID")
+		return nil, err
+	}
+
+	claims := &model.JwtData{
+		Username: spiffeId,
+		SpiffeId: spiffeId,
+	}
+	claims.Subject = spiffeId
+
+	return claims, nil
+}
+
+// About get the SPIFFE ID of a X509-SVID, it must have exactly one URI SAN with the spiffe scheme
+func spiffeIdFromCert(certX509 *x509.Certificate) (string, error) {
+	if len(certX509.URIs) != 1 {
+		return "", erro.ErrSpiffeId
+	}
+
+	uri := certX509.URIs[0]
+	if uri.Scheme != "spiffe" || uri.User != nil || uri.Port() != "" ||
+	   uri.RawQuery != "" || uri.Fragment != "" || uri.Opaque != "" ||
+	   !spiffeTrustDomain.MatchString(uri.Host) {
+		return "", erro.ErrSpiffeId
+	}
+
+	if uri.Path != "" {
+		for _, segment := range strings.Split(strings.TrimPrefix(uri.Path, "/"), "/") {
+			if segment == "." || segment == ".." || !spiffePathSegment.MatchString(segment) {
+				return "", erro.ErrSpiffeId
+			}
+		}
+	}
+
+	return uri.String(), nil
+}
+
+// About check if the SPIFFE ID is allowed in the path (exact SPIFFE ID or its trust domain)
+// The * path is used when the path is not informed in the allowlist
+func (w *WorkerService) spiffeValidation(ctx context.Context, spiffeId string, path string) bool {
+	if !w.SpiffeEnabled() {
+		return false
+	}
+
+	allowlist, ok := w.appServer.SpiffeAuthorization.Allowlist[path]
+	if !ok {
+		allowlist = w.appServer.SpiffeAuthorization.Allowlist["*"]
+	}
+
+	trustDomain := "spiffe://" + strings.SplitN(strings.TrimPrefix(spiffeId, "spiffe://"), "/", 2)[0]
+	for _, allowed := range allowlist {
+		if allowed == spiffeId || allowed == trustDomain {
+			return true
+		}
+	}
+
+	w.logger.Warn().
+		Ctx(ctx).
+		Str("spiffe_id", spiffeId).
+		Str("path", path).
+		Msg("spiffe id NOT ALLOWED")
+
+	return false
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"context"
+	"testing"
+	"net/url"
+	"crypto/x509"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// issueSvid issues a client certificate with the URI SANs
+func (c *testCa) issueSvid(t *testing.T, serial int64, uris ...string) *x509.Certificate {
+	t.Helper()
+
+	template := clientTemplate(serial, "client.example.com")
+	for _, uri := range uris {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.URIs = append(template.URIs, parsed)
+	}
+	return c.issueTemplate(t, template).cert
+}
+
+func TestSpiffePrincipal(t *testing.T) {
+	ca := newTestCa(t, "ca-01")
+
+	tests := []struct {
+		name		string
+		cert		*x509.Certificate
+		want		string
+		wantErr		error
+	}{
+		{name: "workload", cert: ca.issueSvid(t, 10, "spiffe://example.org/ns/payments/sa/api"), want: "spiffe://example.org/ns/payments/sa/api"},
+		{name: "trust domain only", cert: ca.issueSvid(t, 11, "spiffe://example.org"), want: "spiffe://example.org"},
+		{name: "without uri", cert: ca.issueSvid(t, 12), wantErr: erro.ErrSpiffeId},
+		{name: "two uris", cert: ca.issueSvid(t, 13, "spiffe://example.org/a", "spiffe://example.org/b"), wantErr: erro.ErrSpiffeId},
+		{name: "not spiffe scheme", cert: ca.issueSvid(t, 14, "https://example.org/a"), wantErr: erro.ErrSpiffeId},
+		{name: "upper case trust domain", cert: ca.issueSvid(t, 15, "spiffe://Example.org/a"), wantErr: erro.ErrSpiffeId},
+		{name: "port", cert: ca.issueSvid(t, 16, "spiffe://example.org:8443/a"), wantErr: erro.ErrSpiffeId},
+		{name: "query", cert: ca.issueSvid(t, 17, "spiffe://example.org/a?b=c"), wantErr: erro.ErrSpiffeId},
+		{name: "dot segment", cert: ca.issueSvid(t, 18, "spiffe://example.org/a/../b"), wantErr: erro.ErrSpiffeId},
+		{name: "empty segment", cert: ca.issueSvid(t, 19, "spiffe://example.org/a//b"), wantErr: erro.ErrSpiffeId},
+		{name: "no certificate", wantErr: erro.ErrSpiffeId},
+	}
+
+	w := newTestWorkerService(t, &model.AppServer{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := w.SpiffePrincipal(context.Background(), tt.cert)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SpiffePrincipal() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if claims.SpiffeId != tt.want || claims.Username != tt.want || claims.Subject != tt.want {
+				t.Fatalf("spiffe id = %q, username = %q, sub = %q, want %q", claims.SpiffeId, claims.Username, claims.Subject, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpiffeScopeValidation(t *testing.T) {
+	spiffeAuthorization := &model.SpiffeAuthorization{
+		Enabled: true,
+		Allowlist: map[string][]string{
+			"payments/transfer": {"spiffe://example.org/ns/payments/sa/api"},
+			"payments/info": {"spiffe://example.org"},
+			"*": {"spiffe://admin.example.org/sa/ops"},
+		},
+	}
+
+	tests := []struct {
+		name				string
+		spiffeAuthorization	*model.SpiffeAuthorization
+		spiffeId			string
+		arn					string
+		want				bool
+	}{
+		{name: "spiffe id allowed", spiffeId: "spiffe://example.org/ns/payments/sa/api", arn: "POST/payments/transfer", want: true},
+		{name: "spiffe id not allowed", spiffeId: "spiffe://example.org/ns/orders/sa/api", arn: "POST/payments/transfer", want: false},
+		{name: "trust domain allowed", spiffeId: "spiffe://example.org/ns/orders/sa/api", arn: "GET/payments/info", want: true},
+		{name: "other trust domain", spiffeId: "spiffe://example.com/ns/orders/sa/api", arn: "GET/payments/info", want: false},
+		{name: "trust domain is not a prefix", spiffeId: "spiffe://example.org.evil/sa/api", arn: "GET/payments/info", want: false},
+		{name: "any path", spiffeId: "spiffe://admin.example.org/sa/ops", arn: "DELETE/orders/1", want: true},
+		{name: "path in the allowlist not any path", spiffeId: "spiffe://admin.example.org/sa/ops", arn: "POST/payments/transfer", want: false},
+		{name: "path not in the allowlist", spiffeId: "spiffe://example.org/ns/payments/sa/api", arn: "DELETE/orders/1", want: false},
+		{name: "disabled", spiffeAuthorization: &model.SpiffeAuthorization{Allowlist: spiffeAuthorization.Allowlist}, spiffeId: "spiffe://example.org/ns/payments/sa/api", arn: "POST/payments/transfer", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.spiffeAuthorization == nil {
+				tt.spiffeAuthorization = spiffeAuthorization
+			}
+			w := newTestWorkerService(t, &model.AppServer{SpiffeAuthorization: tt.spiffeAuthorization})
+
+			// the scopes of the claims are not used by the SPIFFE ID principal
+			claims := model.JwtData{SpiffeId: tt.spiffeId, Scope: []string{"admin"}}
+			if got := w.ScopeValidation(context.Background(), claims, testArnPrefix + tt.arn, &model.RequestData{}); got != tt.want {
+				t.Fatalf("ScopeValidation(%s) = %v, want %v", tt.arn, got, tt.want)
+			}
+		})
+	}
+}
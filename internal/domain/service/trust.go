@@ -0,0 +1,218 @@
+package service
+
+import (
+	"fmt"
+	"context"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// issuerTrust is a trusted issuer with its own key sets and remote jwks
+// The local issuer uses the key sets of the lambda (nil here)
+type issuerTrust struct {
+	trustedIssuer	model.TrustedIssuer
+	keySets			map[string]*KeySet
+	remoteKeys		*remoteKeys
+}
+
+// About set the trusted issuers, each one with the key source informed (by issuer)
+// The key source "local" uses the keys of the lambda, the others must have a KeySource
+func (w *WorkerService) SetTrustConfig(ctx context.Context,
+									   trustConfig *model.TrustConfig,
+									   keySources map[string]KeySource) error {
+	w.logger.Info().
+		Ctx(ctx).
+		Str("func","SetTrustConfig").Send()
+
+	if err := validateTrustConfig(trustConfig, keySources, w.hmacRefresh != nil); err != nil {
+		return err
+	}
+
+	oidc := model.Oidc{}
+	if w.appServer.Oidc != nil {
+		oidc = *w.appServer.Oidc
+	}
+
+	trustedIssuers := make(map[string]*issuerTrust, len(trustConfig.Issuers))
+	for _, trustedIssuer := range trustConfig.Issuers {
+		issuerTrust := &issuerTrust{trustedIssuer: trustedIssuer}
+
+		if trustedIssuer.KeySource != "local" {
+			issuerTrust.keySets = make(map[string]*KeySet, len(jwkTypes))
+			for authModel := range jwkTypes {
+				issuerTrust.keySets[authModel] = NewKeySet("")
+			}
+			issuerTrust.remoteKeys = &remoteKeys{
+				keySource: keySources[trustedIssuer.Issuer],
+				keySets: issuerTrust.keySets,
+				oidc: oidc,
+			}
+
+			// a issuer unavailable must not stop the lambda, the jwks is fetched again later
+			if err := issuerTrust.remoteKeys.fetch(ctx, w); err != nil {
+				w.logger.Error().
+					Ctx(ctx).
+					Err(err).
+					Str("issuer", trustedIssuer.Issuer).
+					Msg("erro fetch the trusted issuer jwks")
+			}
+		}
+
+		trustedIssuers[trustedIssuer.Issuer] = issuerTrust
+	}
+
+	w.trustedIssuers = trustedIssuers
+	w.TokenSignedValidation = w.tokenValidationTrusted
+
+	return nil
+}
+
+// About validate the trust config, a invalid config must stop the lambda
+// The HS256 algorithms of the local issuer need the HS256 secret loaded (AUTHENTICATION_MODEL HS256 or MIXED)
+func validateTrustConfig(trustConfig *model.TrustConfig, keySources map[string]KeySource, hmacLoaded bool) error {
+	if trustConfig == nil || len(trustConfig.Issuers) == 0 {
+		return fmt.Errorf("%w: trust config without issuers", erro.ErrBadRequest)
+	}
+
+	issuers := make(map[string]bool, len(trustConfig.Issuers))
+	for i, trustedIssuer := range trustConfig.Issuers {
+		if trustedIssuer.Issuer == "" {
+			return fmt.Errorf("%w: trust config issuer[%d] without issuer", erro.ErrBadRequest, i)
+		}
+		if issuers[trustedIssuer.Issuer] {
+			return fmt.Errorf("%w: trust config issuer %s duplicated", erro.ErrBadRequest, trustedIssuer.Issuer)
+		}
+		issuers[trustedIssuer.Issuer] = true
+
+		if trustedIssuer.KeySource == "" {
+			return fmt.Errorf("%w: trust config issuer %s without key_source", erro.ErrBadRequest, trustedIssuer.Issuer)
+		}
+		if trustedIssuer.KeySource != "local" && keySources[trustedIssuer.Issuer] == nil {
+			return fmt.Errorf("%w: trust config issuer %s key_source %s not loaded", erro.ErrBadRequest, trustedIssuer.Issuer, trustedIssuer.KeySource)
+		}
+
+		if len(trustedIssuer.AllowedAlgorithms) == 0 {
+			return fmt.Errorf("%w: trust config issuer %s without allowed_algorithms", erro.ErrBadRequest, trustedIssuer.Issuer)
+		}
+		for _, alg := range trustedIssuer.AllowedAlgorithms {
+			authModel, ok := algorithmModels[alg]
+			if !ok {
+				return fmt.Errorf("%w: trust config issuer %s algorithm %s not supported", erro.ErrBadRequest, trustedIssuer.Issuer, alg)
+			}
+			// the HS256 secret is a key of the lambda, never of a remote issuer
+			if authModel == "HS256" && trustedIssuer.KeySource != "local" {
+				return fmt.Errorf("%w: trust config issuer %s algorithm %s requires key_source local", erro.ErrBadRequest, trustedIssuer.Issuer, alg)
+			}
+			if authModel == "HS256" && !hmacLoaded {
+				return fmt.Errorf("%w: trust config issuer %s algorithm %s requires the HS256 secret (AUTHENTICATION_MODEL HS256 or MIXED)", erro.ErrBadRequest, trustedIssuer.Issuer, alg)
+			}
+		}
+	}
+
+	return nil
+}
+
+// About check if the issuer uses the keys of the lambda (key source local), without trust config all the tokens are local
+func (w *WorkerService) localIssuer(iss string) bool {
+	if w.trustedIssuers == nil {
+		return true
+	}
+	issuerTrust, ok := w.trustedIssuers[iss]
+	return ok && issuerTrust.trustedIssuer.KeySource == "local"
+}
+
+// ------------------------- TRUSTED ISSUERS ------------------------------/
+// About check token of one of the trusted issuers
+// The issuer is choosen by the token iss, the token is verified only with the keys, algorithms and audiences of that issuer
+func (w *WorkerService) tokenValidationTrusted(ctx context.Context, bearerToken string)( *model.JwtData, error){
+	w.logger.Info().
+		Str("func","tokenValidationTrusted").Send()
+
+	unverifiedClaims := &model.JwtData{}
+	unverifiedToken, _, err := jwt.NewParser().ParseUnverified(bearerToken, unverifiedClaims)
+	if err != nil {
+		return nil, w.tokenValidationError(err)
+	}
+
+	issuerTrust, ok := w.trustedIssuers[unverifiedClaims.ISS]
+	if !ok {
+		w.logger.Warn().
+			Str("iss", unverifiedClaims.ISS).
+			Msg("token issuer NOT TRUSTED")
+		return nil, erro.ErrTokenIssuer
+	}
+	trustedIssuer := issuerTrust.trustedIssuer
+
+	if err := w.algorithmValidation(unverifiedToken, trustedIssuer.AllowedAlgorithms, trustedIssuer.Issuer); err != nil {
+		return nil, err
+	}
+
+	authModel := algorithmModels[unverifiedToken.Method.Alg()]
+
+	keySets, remote := issuerTrust.keySets, issuerTrust.remoteKeys
+	if trustedIssuer.KeySource == "local" {
+		keySets, remote = w.keySets, w.remoteKeys
+		if authModel == "HS256" {
+			w.refreshHmacKeys(ctx)
+		}
+	}
+
+	claims, err := w.tokenVerify(ctx,
+								 bearerToken,
+								 authModel,
+								 keySets,
+								 remote,
+								 trustedIssuer.AllowedAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ISS != trustedIssuer.Issuer {
+		return nil, erro.ErrTokenIssuer
+	}
+
+	if len(trustedIssuer.Audiences) > 0 && !slices.ContainsFunc(claims.Audience, func(audience string) bool {
+		return slices.Contains(trustedIssuer.Audiences, audience)
+	}) {
+		w.logger.Warn().
+			Str("iss", claims.ISS).
+			Strs("aud", claims.Audience).
+			Msg("token audience NOT ALLOWED for the issuer")
+		return nil, erro.ErrTokenAudience
+	}
+
+	applyClaimMapping(claims, trustedIssuer.ClaimMapping)
+
+	return claims, nil
+}
+
+// About copy the issuer claims named in the claim mapping (ex: scp, sub, org_id) to the scope, username and tenant
+func applyClaimMapping(claims *model.JwtData, claimMapping model.ClaimMapping) {
+	rawClaims := claims.RawClaims
+
+	if claimMapping.Username != "" {
+		claims.Username = claimString(rawClaims[claimMapping.Username])
+	}
+	if claimMapping.Tenant != "" {
+		claims.Tenant = claimString(rawClaims[claimMapping.Tenant])
+	}
+	if claimMapping.Scope != "" {
+		claims.Scope = normalizeScopes(claimStrings(rawClaims[claimMapping.Scope]))
+	}
+}
+
+// About a claim as string (string or number)
+func claimString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"time"
+	"errors"
+	"slices"
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+func TestValidateTrustConfig(t *testing.T) {
+	keySources := map[string]KeySource{"partner": &testKeySource{}}
+
+	tests := []struct {
+		name		string
+		issuers		[]model.TrustedIssuer
+		hmacLoaded	bool
+		wantErr		error
+	}{
+		{name: "valid", issuers: []model.TrustedIssuer{
+			{Issuer: "local", KeySource: "local", AllowedAlgorithms: []string{"RS256"}},
+			{Issuer: "partner", KeySource: "oidc", AllowedAlgorithms: []string{"ES256"}},
+		}},
+		{name: "without issuers", wantErr: erro.ErrBadRequest},
+		{name: "duplicated issuer", issuers: []model.TrustedIssuer{
+			{Issuer: "local", KeySource: "local", AllowedAlgorithms: []string{"RS256"}},
+			{Issuer: "local", KeySource: "local", AllowedAlgorithms: []string{"ES256"}},
+		}, wantErr: erro.ErrBadRequest},
+		{name: "without key source", issuers: []model.TrustedIssuer{{Issuer: "local", AllowedAlgorithms: []string{"RS256"}}}, wantErr: erro.ErrBadRequest},
+		{name: "key source not loaded", issuers: []model.TrustedIssuer{{Issuer: "other", KeySource: "oidc", AllowedAlgorithms: []string{"RS256"}}}, wantErr: erro.ErrBadRequest},
+		{name: "algorithm not supported", issuers: []model.TrustedIssuer{{Issuer: "local", KeySource: "local", AllowedAlgorithms: []string{"none"}}}, wantErr: erro.ErrBadRequest},
+		{name: "HS256 of a remote issuer", issuers: []model.TrustedIssuer{{Issuer: "partner", KeySource: "oidc", AllowedAlgorithms: []string{"HS256"}}}, hmacLoaded: true, wantErr: erro.ErrBadRequest},
+		{name: "HS256 local with the secret", issuers: []model.TrustedIssuer{{Issuer: "local", KeySource: "local", AllowedAlgorithms: []string{"RS256", "HS256"}}}, hmacLoaded: true},
+		{name: "HS256 local without the secret", issuers: []model.TrustedIssuer{{Issuer: "local", KeySource: "local", AllowedAlgorithms: []string{"RS256", "HS256"}}}, wantErr: erro.ErrBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTrustConfig(&model.TrustConfig{Issuers: tt.issuers}, keySources, tt.hmacLoaded)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("validateTrustConfig() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenValidationTrusted(t *testing.T) {
+	rsaKey := newTestRsaKey(t)
+	partner := newTestKeySource(t, 10 * time.Minute, "partner-01")
+	partner.publish("partner-01")
+
+	w := newTestWorkerService(t, &model.AppServer{
+		RsaKey: &model.RsaKey{Kid: "rsa-01", RsaPublic: &rsaKey.PublicKey},
+		TokenValidation: map[string]*model.TokenValidation{"RSA": {AllowedAlgorithms: []string{"RS256"}}},
+		Oidc: &model.Oidc{DefaultCacheTTL: 15 * time.Minute, MinCacheTTL: time.Minute, MaxCacheTTL: time.Hour, UnknownKidInterval: time.Hour},
+	})
+	if err := w.SetTrustConfig(context.Background(), &model.TrustConfig{Issuers: []model.TrustedIssuer{
+		{Issuer: "lambda-go-oauth2", KeySource: "local", AllowedAlgorithms: []string{"RS256"}},
+		{Issuer: "https://partner.localhost", KeySource: "oidc", AllowedAlgorithms: []string{"RS256"}, Audiences: []string{"api-partner"},
+		 ClaimMapping: model.ClaimMapping{Scope: "scp", Username: "sub", Tenant: "org_id"}},
+	}}, map[string]KeySource{"https://partner.localhost": partner}); err != nil {
+		t.Fatalf("SetTrustConfig() error = %v", err)
+	}
+
+	// claims returns the test claims of the issuer with the extra claims informed
+	claims := func(iss string, extra jwt.MapClaims) jwt.MapClaims {
+		mapClaims := testClaims()
+		mapClaims["iss"] = iss
+		for name, value := range extra {
+			mapClaims[name] = value
+		}
+		return mapClaims
+	}
+
+	partnerClaims := jwt.MapClaims{"aud": "api-partner", "sub": "user-02", "org_id": 10, "scp": []string{"account:read", " account:read"}}
+
+	tests := []struct {
+		name			string
+		token			string
+		wantErr			error
+		wantUsername	string
+		wantTenant		string
+		wantScope		[]string
+	}{
+		{name: "local issuer", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-01", claims("lambda-go-oauth2", nil)), wantUsername: "user-01"},
+		{name: "remote issuer with the claim mapping", token: signTestToken(t, jwt.SigningMethodRS256, partner.keys["partner-01"], "partner-01", claims("https://partner.localhost", partnerClaims)),
+		 wantUsername: "user-02", wantTenant: "10", wantScope: []string{"account:read"}},
+		{name: "issuer not trusted", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-01", claims("other", nil)), wantErr: erro.ErrTokenIssuer},
+		{name: "remote key with the local issuer", token: signTestToken(t, jwt.SigningMethodRS256, partner.keys["partner-01"], "partner-01", claims("lambda-go-oauth2", nil)), wantErr: erro.ErrSignatureInvalid},
+		{name: "local key with the remote issuer", token: signTestToken(t, jwt.SigningMethodRS256, rsaKey, "rsa-01", claims("https://partner.localhost", partnerClaims)), wantErr: erro.ErrKidNotFound},
+		{name: "audience of another api", token: signTestToken(t, jwt.SigningMethodRS256, partner.keys["partner-01"], "partner-01", claims("https://partner.localhost", jwt.MapClaims{"aud": "api-other"})), wantErr: erro.ErrTokenAudience},
+		{name: "audience missing", token: signTestToken(t, jwt.SigningMethodRS256, partner.keys["partner-01"], "partner-01", claims("https://partner.localhost", nil)), wantErr: erro.ErrTokenAudience},
+		{name: "algorithm of another issuer", token: signTestToken(t, jwt.SigningMethodHS256, []byte("secret-01"), "", claims("lambda-go-oauth2", nil)), wantErr: erro.ErrAlgorithmNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := w.TokenSignedValidation(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TokenSignedValidation() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Username != tt.wantUsername || got.Tenant != tt.wantTenant || !slices.Equal(got.Scope, tt.wantScope) {
+				t.Fatalf("TokenSignedValidation() username = %q, tenant = %q, scope = %v, want %q, %q, %v",
+					got.Username, got.Tenant, got.Scope, tt.wantUsername, tt.wantTenant, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestApplyClaimMapping(t *testing.T) {
+	rawClaims := jwt.MapClaims{
+		"sub": "user-02",
+		"org_id": float64(10),
+		"scp": []interface{}{"account:read", "account:write account:read", ""},
+		"roles": "account:admin",
+	}
+
+	tests := []struct {
+		name			string
+		claimMapping	model.ClaimMapping
+		wantUsername	string
+		wantTenant		string
+		wantScope		[]string
+	}{
+		{name: "without mapping keeps the claims", wantUsername: "user-01", wantTenant: "tenant-01", wantScope: []string{"info"}},
+		{name: "array scope", claimMapping: model.ClaimMapping{Scope: "scp", Username: "sub", Tenant: "org_id"},
+		 wantUsername: "user-02", wantTenant: "10", wantScope: []string{"account:read", "account:write"}},
+		{name: "string scope", claimMapping: model.ClaimMapping{Scope: "roles"}, wantUsername: "user-01", wantTenant: "tenant-01", wantScope: []string{"account:admin"}},
+		{name: "claim missing", claimMapping: model.ClaimMapping{Scope: "scope_other", Username: "email"}, wantTenant: "tenant-01", wantScope: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &model.JwtData{Username: "user-01", Tenant: "tenant-01", Scope: model.ScopeList{"info"}, RawClaims: rawClaims}
+
+			applyClaimMapping(claims, tt.claimMapping)
+
+			if claims.Username != tt.wantUsername || claims.Tenant != tt.wantTenant || !slices.Equal(claims.Scope, tt.wantScope) {
+				t.Fatalf("applyClaimMapping() username = %q, tenant = %q, scope = %v, want %q, %q, %v",
+					claims.Username, claims.Tenant, claims.Scope, tt.wantUsername, tt.wantTenant, tt.wantScope)
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -18,11 +20,39 @@ var (
 	envLoaded bool
 )
 
+// defaultAlgorithms are the signing algorithms allowed by default in each authentication model
+var defaultAlgorithms = map[string][]string{
+	"RSA":		{"RS256"},
+	"ECDSA":	{"ES256", "ES384"},
+	"EDDSA":	{"EdDSA"},
+	"HS256":	{"HS256"},
+	"MIXED":	{"RS256", "HS256"},
+}
+
+// supportedAlgorithms are the signing algorithms accepted by each authentication model
+var supportedAlgorithms = map[string][]string{
+	"RSA":		{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"},
+	"ECDSA":	{"ES256", "ES384", "ES512"},
+	"EDDSA":	{"EdDSA"},
+	"HS256":	{"HS256", "HS384", "HS512"},
+	"MIXED":	{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA", "HS256", "HS384", "HS512"},
+}
+
 // AllConfig aggregates all configuration
 type AllConfig struct {
 	Application *model.Application
 	AwsService  *model.AwsService
 	OtelTrace   *go_core_otel_trace.EnvTrace
+	TokenValidation map[string]*model.TokenValidation
+	ClaimValidation *model.ClaimValidation
+	Revocation		*model.Revocation
+	Rbac			*model.Rbac
+	HmacSecret		*model.HmacSecret
+	Oidc			*model.Oidc
+	Introspection	*model.Introspection
+	TokenEncryption	*model.TokenEncryption
+	ClientCertValidation *model.ClientCertValidation
+	SpiffeAuthorization *model.SpiffeAuthorization
 }
 
 // ConfigLoader handles loading and validating all configurations
@@ -60,10 +90,65 @@ func (cl *ConfigLoader) LoadAll() (*AllConfig, error) {
 		return nil, fmt.Errorf("FAILED to load OTEL config: %w", err)
 	}
 
+	tokenValidation, err := cl.loadTokenValidation()
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to load token validation config: %w", err)
+	}
+
+	claimValidation, err := cl.loadClaimValidation()
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to load claim validation config: %w", err)
+	}
+
+	revocation, err := cl.loadRevocation()
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to load revocation config: %w", err)
+	}
+
+	rbac, err := cl.loadRbac()
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to load rbac config: %w", err)
+	}
+
+	hmacSecret, err := cl.loadHmacSecret()
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to load hmac secret config: %w", err)
+	}
+
+	oidc, err := cl.loadOidc()
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to load oidc config: %w", err)
+	}
+
+	introspection, err := cl.loadIntrospection()
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to load introspection config: %w", err)
+	}
+
+	clientCertValidation, err := cl.loadClientCertValidation()
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to load client cert validation config: %w", err)
+	}
+
+	spiffeAuthorization, err := cl.loadSpiffeAuthorization()
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to load spiffe authorization config: %w", err)
+	}
+
 	return &AllConfig{
 		Application:	app,
 		AwsService:		awsService,
 		OtelTrace:		otel,
+		TokenValidation: tokenValidation,
+		ClaimValidation: claimValidation,
+		Revocation:		revocation,
+		Rbac:			rbac,
+		HmacSecret:		hmacSecret,
+		Oidc:			oidc,
+		Introspection:	introspection,
+		TokenEncryption: cl.loadTokenEncryption(),
+		ClientCertValidation: clientCertValidation,
+		SpiffeAuthorization: spiffeAuthorization,
 	}, nil
 }
 
@@ -76,6 +161,7 @@ func (cl *ConfigLoader) loadApplication() (*model.Application, error) {
 		Name:          getEnvString("APP_NAME", "go-cart"),
 		Account:       getEnvString("ACCOUNT", ""),
 		Env:           getEnvString("ENV", "dev"),
+		AuthenticationModel: getEnvString("AUTHENTICATION_MODEL", "HS256"),
 		StdOutLogGroup: getEnvBool("OTEL_STDOUT_LOG_GROUP", false),
 		LogGroup:      getEnvString("LOG_GROUP", ""),
 		LogLevel:      getEnvString("LOG_LEVEL", "info"),
@@ -84,6 +170,16 @@ func (cl *ConfigLoader) loadApplication() (*model.Application, error) {
 		OtelMetrics:   getEnvBool("OTEL_METRICS", false),
 	}
 
+	// HSA is the name of the HS256 model in the docs
+	if app.AuthenticationModel == "HSA" {
+		app.AuthenticationModel = "HS256"
+	}
+
+	// a typo in the model must not fall back to another signature validation
+	if _, ok := supportedAlgorithms[app.AuthenticationModel]; !ok {
+		return nil, fmt.Errorf("AUTHENTICATION_MODEL %s is not supported (supported: RSA,ECDSA,EDDSA,HS256,MIXED)", app.AuthenticationModel)
+	}
+
 	cl.logger.Info().
 		Interface("application", app).
 		Msg("Application configuration loaded SUCCESSFULLY")
@@ -104,7 +200,13 @@ func (cl *ConfigLoader) loadAws() (*model.AwsService, error) {
 		FilePathRSA: getEnvString("RSA_FILE_PATH", ""),
 		FileNameRSAPrivKey: getEnvString("RSA_PRIV_FILE_KEY", ""),
 		FileNameRSAPubKey: getEnvString("RSA_PUB_FILE_KEY", ""),
+		FileNameECPubKey: getEnvString("EC_PUB_FILE_KEY", ""),
+		FileNameEdPubKey: getEnvString("ED_PUB_FILE_KEY", ""),
 		FileNameCrlKey: getEnvString("CRL_FILE_KEY", ""),
+		FileNameCaCertKey: getEnvString("CA_CERT_FILE_KEY", ""),
+		JwksSource: getEnvString("JWKS_SOURCE", ""),
+		TrustConfigSource: getEnvString("TRUST_CONFIG_SOURCE", ""),
+		ScopePolicySource: getEnvString("SCOPE_POLICY_SOURCE", ""),
 	}
 
 	cl.logger.Info().
@@ -142,6 +244,347 @@ func (cl *ConfigLoader) loadOtel() (*go_core_otel_trace.EnvTrace, error) {
 	return otel, nil
 }
 
+// loadTokenValidation loads the token rules for each authentication model
+func (cl *ConfigLoader) loadTokenValidation() (map[string]*model.TokenValidation, error) {
+	cl.logger.Debug().Msg("Loading token validation configuration")
+
+	tokenValidation := map[string]*model.TokenValidation{}
+
+	// each model is configured by the env prefix (ex: RSA_ALLOWED_ALGS, RSA_LEEWAY)
+	for authModel, defaultAlgorithms := range defaultAlgorithms {
+		validation := &model.TokenValidation{
+			AllowedAlgorithms: getEnvList(authModel+"_ALLOWED_ALGS", defaultAlgorithms),
+			RequireIssuedAt: getEnvBool(authModel+"_REQUIRE_IAT", false),
+			RequireNotBefore: getEnvBool(authModel+"_REQUIRE_NBF", false),
+		}
+
+		if len(validation.AllowedAlgorithms) == 0 {
+			return nil, fmt.Errorf("allowed algorithms for %s not informed", authModel)
+		}
+		// the algorithm must match the key family of the model (ex: PS256 and RS256 share the rsa key)
+		for _, alg := range validation.AllowedAlgorithms {
+			if !slices.Contains(supportedAlgorithms[authModel], alg) {
+				return nil, fmt.Errorf("algorithm %s is not supported for %s (supported: %s)", 
+										alg, 
+										authModel, 
+										strings.Join(supportedAlgorithms[authModel], ","))
+			}
+		}
+
+		var err error
+		if validation.Leeway, err = getEnvDuration(authModel+"_LEEWAY", 0); err != nil {
+			return nil, err
+		}
+		if validation.MaxTokenAge, err = getEnvDuration(authModel+"_MAX_TOKEN_AGE", 0); err != nil {
+			return nil, err
+		}
+		if validation.MaxTokenLifetime, err = getEnvDuration(authModel+"_MAX_TOKEN_LIFETIME", 0); err != nil {
+			return nil, err
+		}
+		if validation.Leeway < 0 || validation.MaxTokenAge < 0 || validation.MaxTokenLifetime < 0 {
+			return nil, fmt.Errorf("leeway, max token age and max token lifetime of %s must be positive", authModel)
+		}
+
+		tokenValidation[authModel] = validation
+	}
+
+	// the mixed allowlist can not open an algorithm not allowed by its own model
+	for _, alg := range tokenValidation["MIXED"].AllowedAlgorithms {
+		ownModel := ""
+		for authModel, algorithms := range supportedAlgorithms {
+			if authModel != "MIXED" && slices.Contains(algorithms, alg) {
+				ownModel = authModel
+			}
+		}
+		if !slices.Contains(tokenValidation[ownModel].AllowedAlgorithms, alg) {
+			return nil, fmt.Errorf("algorithm %s of MIXED_ALLOWED_ALGS must be allowed in %s_ALLOWED_ALGS", alg, ownModel)
+		}
+	}
+
+	cl.logger.Info().
+		Interface("tokenValidation", tokenValidation).
+		Msg("Token validation configuration loaded SUCCESSFULLY")
+
+	return tokenValidation, nil
+}
+
+// loadClaimValidation loads the expected issuers, audiences (per api id) and token_use
+func (cl *ConfigLoader) loadClaimValidation() (*model.ClaimValidation, error) {
+	cl.logger.Debug().Msg("Loading claim validation configuration")
+
+	claimValidation := &model.ClaimValidation{
+		Issuers:	getEnvList("TOKEN_ISSUERS", nil),
+		Audiences:	map[string][]string{},
+		TokenUse:	getEnvList("TOKEN_USE", nil),
+		ScopeClaims: getEnvList("SCOPE_CLAIMS", []string{"scope", "scp"}),
+	}
+
+	// format: apiId=aud1,aud2;apiId2=aud3 (use * as api id for any api)
+	audiences, err := getEnvMapList("TOKEN_AUDIENCES")
+	if err != nil {
+		return nil, err
+	}
+	claimValidation.Audiences = audiences
+
+	cl.logger.Info().
+		Interface("claimValidation", claimValidation).
+		Msg("Claim validation configuration loaded SUCCESSFULLY")
+
+	return claimValidation, nil
+}
+
+// loadRevocation loads the token revocation (jwt_id denylist) configuration
+func (cl *ConfigLoader) loadRevocation() (*model.Revocation, error) {
+	cl.logger.Debug().Msg("Loading revocation configuration")
+
+	cacheTTL, err := getEnvDuration("REVOCATION_CACHE_TTL", 30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSize, err := getEnvInt("REVOCATION_CACHE_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	revocation := &model.Revocation{
+		Enabled:	getEnvBool("REVOCATION_CHECK", false),
+		CacheTTL:	cacheTTL,
+		CacheSize:	cacheSize,
+	}
+
+	cl.logger.Info().
+		Interface("revocation", revocation).
+		Msg("Revocation configuration loaded SUCCESSFULLY")
+
+	return revocation, nil
+}
+
+// loadRbac loads the user scopes (CredentialScope) configuration
+func (cl *ConfigLoader) loadRbac() (*model.Rbac, error) {
+	cl.logger.Debug().Msg("Loading rbac configuration")
+
+	cacheTTL, err := getEnvDuration("RBAC_CACHE_TTL", 30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSize, err := getEnvInt("RBAC_CACHE_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	rbac := &model.Rbac{
+		Enabled:	getEnvBool("RBAC_USER_SCOPES", false),
+		CacheTTL:	cacheTTL,
+		CacheSize:	cacheSize,
+	}
+
+	cl.logger.Info().
+		Interface("rbac", rbac).
+		Msg("Rbac configuration loaded SUCCESSFULLY")
+
+	return rbac, nil
+}
+
+// loadSpiffeAuthorization loads the SPIFFE ID allowlist of the service-to-service calls
+func (cl *ConfigLoader) loadSpiffeAuthorization() (*model.SpiffeAuthorization, error) {
+	cl.logger.Debug().Msg("Loading spiffe authorization configuration")
+
+	// format: path=spiffe://td/svc,spiffe://td2;*=spiffe://td (use * as path for any path)
+	allowlist, err := getEnvMapList("SPIFFE_ALLOWLIST")
+	if err != nil {
+		return nil, err
+	}
+	for path, spiffeIds := range allowlist {
+		for _, spiffeId := range spiffeIds {
+			if !strings.HasPrefix(spiffeId, "spiffe://") {
+				return nil, fmt.Errorf("SPIFFE_ALLOWLIST path %s item %s invalid, expected spiffe://trust-domain[/path]", path, spiffeId)
+			}
+		}
+	}
+
+	spiffeAuthorization := &model.SpiffeAuthorization{
+		Enabled:	getEnvBool("SPIFFE_AUTHORIZATION", false),
+		Allowlist:	allowlist,
+	}
+
+	if spiffeAuthorization.Enabled && len(allowlist) == 0 {
+		return nil, fmt.Errorf("SPIFFE_ALLOWLIST is required with SPIFFE_AUTHORIZATION")
+	}
+
+	cl.logger.Info().
+		Interface("spiffeAuthorization", spiffeAuthorization).
+		Msg("Spiffe authorization configuration loaded SUCCESSFULLY")
+
+	return spiffeAuthorization, nil
+}
+
+// loadClientCertValidation loads the mTLS client certificate validation configuration
+func (cl *ConfigLoader) loadClientCertValidation() (*model.ClientCertValidation, error) {
+	cl.logger.Debug().Msg("Loading client cert validation configuration")
+
+	crlRefreshInterval, err := getEnvDuration("CRL_REFRESH_INTERVAL", 1 * time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	ocspTimeout, err := getEnvDuration("OCSP_TIMEOUT", 3 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	ocspCacheTTL, err := getEnvDuration("OCSP_CACHE_TTL", 5 * time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	ocspCacheSize, err := getEnvInt("OCSP_CACHE_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	clientCertValidation := &model.ClientCertValidation{
+		CertBoundRequired:	getEnvBool("CERT_BOUND_TOKEN_REQUIRED", false),
+		CrlRefreshInterval:	crlRefreshInterval,
+		OcspEnabled:		getEnvBool("OCSP_CHECK", false),
+		OcspFailOpen:		getEnvBool("OCSP_FAIL_OPEN", false),
+		OcspTimeout:		ocspTimeout,
+		OcspCacheTTL:		ocspCacheTTL,
+		OcspCacheSize:		ocspCacheSize,
+		ChainValidation:	getEnvBool("CLIENT_CERT_CHAIN_VALIDATION", false),
+	}
+
+	cl.logger.Info().
+		Interface("clientCertValidation", clientCertValidation).
+		Msg("Client cert validation configuration loaded SUCCESSFULLY")
+
+	return clientCertValidation, nil
+}
+
+// loadTokenEncryption loads the encrypted token (jwe) configuration
+func (cl *ConfigLoader) loadTokenEncryption() *model.TokenEncryption {
+	cl.logger.Debug().Msg("Loading token encryption configuration")
+
+	tokenEncryption := &model.TokenEncryption{
+		Required:	getEnvBool("JWE_REQUIRED", false),
+	}
+
+	cl.logger.Info().
+		Interface("tokenEncryption", tokenEncryption).
+		Msg("Token encryption configuration loaded SUCCESSFULLY")
+
+	return tokenEncryption
+}
+
+// loadIntrospection loads the RFC 7662 endpoint used to validate the opaque tokens
+func (cl *ConfigLoader) loadIntrospection() (*model.Introspection, error) {
+	cl.logger.Debug().Msg("Loading introspection configuration")
+
+	cacheTTL, err := getEnvDuration("INTROSPECTION_CACHE_TTL", 5 * time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	negativeCacheTTL, err := getEnvDuration("INTROSPECTION_NEGATIVE_CACHE_TTL", 30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSize, err := getEnvInt("INTROSPECTION_CACHE_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	introspection := &model.Introspection{
+		Endpoint:			getEnvString("INTROSPECTION_ENDPOINT", ""),
+		ClientId:			getEnvString("INTROSPECTION_CLIENT_ID", ""),
+		ClientSecret:		getEnvString("INTROSPECTION_CLIENT_SECRET", ""),
+		ClientSecretName:	getEnvString("INTROSPECTION_CLIENT_SECRET_NAME", ""),
+		CacheTTL:			cacheTTL,
+		NegativeCacheTTL:	negativeCacheTTL,
+		CacheSize:			cacheSize,
+	}
+
+	if introspection.Endpoint != "" && introspection.ClientId == "" {
+		return nil, fmt.Errorf("INTROSPECTION_CLIENT_ID is required with INTROSPECTION_ENDPOINT")
+	}
+
+	cl.logger.Info().
+		Interface("introspection", introspection).
+		Msg("Introspection configuration loaded SUCCESSFULLY")
+
+	return introspection, nil
+}
+
+// loadHmacSecret loads where the HS256 keys are stored
+func (cl *ConfigLoader) loadHmacSecret() (*model.HmacSecret, error) {
+	cl.logger.Debug().Msg("Loading hmac secret configuration")
+
+	refreshInterval, err := getEnvDuration("HS256_SECRET_REFRESH", 5 * time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	hmacSecret := &model.HmacSecret{
+		Provider:			getEnvString("HS256_SECRET_PROVIDER", "secretsmanager"),
+		FilePath:			getEnvString("HS256_SECRET_FILE", ""),
+		RefreshInterval:	refreshInterval,
+	}
+
+	switch hmacSecret.Provider {
+	case "secretsmanager":
+	case "file":
+		if hmacSecret.FilePath == "" {
+			return nil, fmt.Errorf("HS256_SECRET_FILE not informed for the file provider")
+		}
+	default:
+		return nil, fmt.Errorf("HS256_SECRET_PROVIDER %s invalid (secretsmanager or file)", hmacSecret.Provider)
+	}
+
+	cl.logger.Info().
+		Interface("hmacSecret", hmacSecret).
+		Msg("Hmac secret configuration loaded SUCCESSFULLY")
+
+	return hmacSecret, nil
+}
+
+// loadOidc loads the oidc issuer used to fetch the remote jwks
+func (cl *ConfigLoader) loadOidc() (*model.Oidc, error) {
+	cl.logger.Debug().Msg("Loading oidc configuration")
+
+	oidc := &model.Oidc{
+		IssuerUrl: getEnvString("OIDC_ISSUER_URL", ""),
+	}
+
+	var err error
+	if oidc.DefaultCacheTTL, err = getEnvDuration("OIDC_JWKS_CACHE_TTL", 15 * time.Minute); err != nil {
+		return nil, err
+	}
+	if oidc.MinCacheTTL, err = getEnvDuration("OIDC_JWKS_MIN_CACHE_TTL", 1 * time.Minute); err != nil {
+		return nil, err
+	}
+	if oidc.MaxCacheTTL, err = getEnvDuration("OIDC_JWKS_MAX_CACHE_TTL", 24 * time.Hour); err != nil {
+		return nil, err
+	}
+	if oidc.UnknownKidInterval, err = getEnvDuration("OIDC_UNKNOWN_KID_INTERVAL", 30 * time.Second); err != nil {
+		return nil, err
+	}
+
+	if oidc.IssuerUrl != "" && !strings.HasPrefix(oidc.IssuerUrl, "https://") && !strings.HasPrefix(oidc.IssuerUrl, "http://") {
+		return nil, fmt.Errorf("OIDC_ISSUER_URL %s must be a http(s) url", oidc.IssuerUrl)
+	}
+	if oidc.MinCacheTTL > oidc.MaxCacheTTL {
+		return nil, fmt.Errorf("OIDC_JWKS_MIN_CACHE_TTL must be lower than OIDC_JWKS_MAX_CACHE_TTL")
+	}
+
+	cl.logger.Info().
+		Interface("oidc", oidc).
+		Msg("Oidc configuration loaded SUCCESSFULLY")
+
+	return oidc, nil
+}
+
 // Helper functions
 // getEnvString retrieves environment variable as string with default
 func getEnvString(key, defaultVal string) string {
@@ -174,3 +617,67 @@ func getEnvInt(key string, defaultVal int) (int, error) {
 
 	return intVal, nil
 }
+
+// getEnvList retrieves environment variable as a comma separated list with default
+func getEnvList(key string, defaultVal []string) []string {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+
+	list := []string{}
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvMapList retrieves environment variable as map of lists (format: key=v1,v2;key2=v3)
+func getEnvMapList(key string) (map[string][]string, error) {
+	mapList := map[string][]string{}
+
+	val := os.Getenv(key)
+	if val == "" {
+		return mapList, nil
+	}
+
+	for _, item := range strings.Split(val, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		keyValues := strings.SplitN(item, "=", 2)
+		if len(keyValues) != 2 || strings.TrimSpace(keyValues[0]) == "" {
+			return nil, fmt.Errorf("%s item %s invalid, expected key=v1,v2", key, item)
+		}
+
+		values := []string{}
+		for _, value := range strings.Split(keyValues[1], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%s item %s without value", key, item)
+		}
+		mapList[strings.TrimSpace(keyValues[0])] = values
+	}
+
+	return mapList, nil
+}
+
+// getEnvDuration retrieves environment variable as duration (ex: 30s, 5m) with error handling
+func getEnvDuration(key string, defaultVal time.Duration) (time.Duration, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal, nil
+	}
+
+	durationVal, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("FAILED to parse %s as duration: %w", key, err)
+	}
+
+	return durationVal, nil
+}
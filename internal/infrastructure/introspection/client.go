@@ -0,0 +1,98 @@
+package introspection
+
+import(
+	"io"
+	"fmt"
+	"time"
+	"context"
+	"strings"
+	"net/url"
+	"net/http"
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// IntrospectionClient calls a RFC 7662 introspection endpoint authenticated by client credentials
+type IntrospectionClient struct {
+	endpoint		string
+	clientId		string
+	clientSecret	string
+	httpClient		*http.Client
+	logger			*zerolog.Logger
+}
+
+// About create a introspection client
+func NewIntrospectionClient(endpoint string,
+							clientId string,
+							clientSecret string,
+							httpClient *http.Client,
+							appLogger *zerolog.Logger) *IntrospectionClient {
+
+	logger := appLogger.With().
+					Str("package", "infrastructure.introspection").
+					Logger()
+
+	logger.Info().
+		Str("func","NewIntrospectionClient").Send()
+
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	return &IntrospectionClient{
+		endpoint: endpoint,
+		clientId: clientId,
+		clientSecret: clientSecret,
+		httpClient: httpClient,
+		logger: &logger,
+	}
+}
+
+// About introspect the token (RFC 7662 section 2)
+// The client authenticates with http basic (client_secret_basic, the id and secret are form encoded)
+func (i *IntrospectionClient) Introspect(ctx context.Context, token string) (*model.IntrospectionResponse, error) {
+	i.logger.Debug().
+		Ctx(ctx).
+		Str("func","Introspect").Send()
+
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(i.clientId), url.QueryEscape(i.clientSecret))
+
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		i.logger.Error().
+			Ctx(ctx).
+			Err(err).Send()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: introspection http status %d", erro.ErrServer, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1 << 20))
+	if err != nil {
+		return nil, err
+	}
+
+	introspectionResponse := model.IntrospectionResponse{}
+	if err := json.Unmarshal(body, &introspectionResponse); err != nil {
+		return nil, fmt.Errorf("%w: %v", erro.ErrUnmarshal, err)
+	}
+
+	return &introspectionResponse, nil
+}
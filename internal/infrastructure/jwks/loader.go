@@ -0,0 +1,81 @@
+package jwks
+
+import(
+	"fmt"
+	"time"
+	"context"
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+	"github.com/lambda-go-oauth2/internal/infrastructure/source"
+)
+
+// JwksLoader loads a jwks document from a file, a S3 object or a HTTP url
+type JwksLoader struct {
+	sourceLoader	*source.SourceLoader
+	logger			*zerolog.Logger
+}
+
+// About create a jwks loader
+func NewJwksLoader(sourceLoader *source.SourceLoader,
+				   appLogger *zerolog.Logger) *JwksLoader {
+
+	logger := appLogger.With().
+					Str("package", "infrastructure.jwks").
+					Logger()
+
+	logger.Info().
+		Str("func","NewJwksLoader").Send()
+
+	return &JwksLoader{
+		sourceLoader: sourceLoader,
+		logger: &logger,
+	}
+}
+
+// About load the jwks
+// source formats: s3://bucket/key, http(s)://host/path, file:///path or a local path
+func (j *JwksLoader) Load(ctx context.Context, source string) (*model.Jwks, error) {
+	j.logger.Info().
+		Ctx(ctx).
+		Str("func","Load").
+		Str("source", source).Send()
+
+	raw, err := j.sourceLoader.Load(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := model.Jwks{}
+	if err := json.Unmarshal(raw, &jwks); err != nil {
+		j.logger.Error().
+			Ctx(ctx).
+			Err(err).Send()
+		return nil, fmt.Errorf("%w: %v", erro.ErrUnmarshal, err)
+	}
+
+	return &jwks, nil
+}
+
+// JwksSource is a jwks source (s3, http or file) reloaded by the default cache ttl
+type JwksSource struct {
+	jwksLoader	*JwksLoader
+	source		string
+}
+
+// About create a jwks source
+func NewJwksSource(jwksLoader *JwksLoader, source string) *JwksSource {
+	return &JwksSource{
+		jwksLoader: jwksLoader,
+		source: source,
+	}
+}
+
+// About fetch the jwks, there is no cache header so the ttl is zero (default)
+func (j *JwksSource) FetchJwks(ctx context.Context) (*model.Jwks, time.Duration, error) {
+	jwks, err := j.jwksLoader.Load(ctx, j.source)
+	return jwks, 0, err
+}
@@ -0,0 +1,185 @@
+package jwks
+
+import(
+	"io"
+	"fmt"
+	"sync"
+	"time"
+	"context"
+	"strings"
+	"strconv"
+	"net/http"
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+// noCacheTTL is the ttl of a jwks response that must not be cached (the min ttl is used)
+const noCacheTTL = time.Duration(-1)
+
+// OidcJwksFetcher discovers the jwks_uri of a OIDC issuer and fetches the jwks
+// The jwks_uri is discovered once, the mutex avoids concurrent discoveries (cache refresh and unknown kid)
+type OidcJwksFetcher struct {
+	mutex		sync.Mutex
+	issuerUrl	string
+	jwksUri		string
+	httpClient	*http.Client
+	logger		*zerolog.Logger
+}
+
+// About create a oidc jwks fetcher
+func NewOidcJwksFetcher(issuerUrl string,
+						httpClient *http.Client,
+						appLogger *zerolog.Logger) *OidcJwksFetcher {
+
+	logger := appLogger.With().
+					Str("package", "infrastructure.jwks").
+					Logger()
+
+	logger.Info().
+		Str("func","NewOidcJwksFetcher").Send()
+
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	return &OidcJwksFetcher{
+		issuerUrl: strings.TrimSuffix(issuerUrl, "/"),
+		httpClient: httpClient,
+		logger: &logger,
+	}
+}
+
+// About get the openid configuration of the issuer
+// The issuer informed in the document must be the same configured
+func (o *OidcJwksFetcher) Discover(ctx context.Context) (*model.OidcConfiguration, error) {
+	o.logger.Info().
+		Ctx(ctx).
+		Str("func","Discover").Send()
+
+	body, _, err := o.get(ctx, o.issuerUrl + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+
+	oidcConfiguration := model.OidcConfiguration{}
+	if err := json.Unmarshal(body, &oidcConfiguration); err != nil {
+		return nil, fmt.Errorf("%w: %v", erro.ErrUnmarshal, err)
+	}
+
+	if strings.TrimSuffix(oidcConfiguration.Issuer, "/") != o.issuerUrl {
+		return nil, fmt.Errorf("%w: discovery issuer %s is not %s", erro.ErrTokenIssuer, oidcConfiguration.Issuer, o.issuerUrl)
+	}
+	if oidcConfiguration.JwksUri == "" {
+		return nil, fmt.Errorf("%w: jwks_uri not informed", erro.ErrBadRequest)
+	}
+
+	return &oidcConfiguration, nil
+}
+
+// About fetch the jwks, the jwks_uri is discovered in the first call
+// The cache ttl is taken from the Cache-Control max-age (or Expires), zero when not informed and negative when it must not be cached
+func (o *OidcJwksFetcher) FetchJwks(ctx context.Context) (*model.Jwks, time.Duration, error) {
+	o.logger.Info().
+		Ctx(ctx).
+		Str("func","FetchJwks").Send()
+
+	jwksUri, err := o.discoverJwksUri(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	body, header, err := o.get(ctx, jwksUri)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jwks := model.Jwks{}
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", erro.ErrUnmarshal, err)
+	}
+
+	return &jwks, cacheTTL(header), nil
+}
+
+// About get the jwks_uri, discovered only once (a failed discovery is tried again in the next fetch)
+func (o *OidcJwksFetcher) discoverJwksUri(ctx context.Context) (string, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.jwksUri == "" {
+		oidcConfiguration, err := o.Discover(ctx)
+		if err != nil {
+			return "", err
+		}
+		o.jwksUri = oidcConfiguration.JwksUri
+	}
+
+	return o.jwksUri, nil
+}
+
+// About http get of a json document
+func (o *OidcJwksFetcher) get(ctx context.Context, url string) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		o.logger.Error().
+			Ctx(ctx).
+			Err(err).Send()
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%w: %s http status %d", erro.ErrServer, url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1 << 20))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return body, resp.Header, nil
+}
+
+// About get the cache ttl from Cache-Control (max-age, no-store, no-cache) or Expires headers
+// no-store, no-cache and a response already expired return noCacheTTL, no cache headers return zero
+func cacheTTL(header http.Header) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store", directive == "no-cache":
+			return noCacheTTL
+		case strings.HasPrefix(directive, "max-age="):
+			if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && seconds >= 0 {
+				age, _ := strconv.Atoi(header.Get("Age"))
+				if ttl := time.Duration(seconds - age) * time.Second; ttl > 0 {
+					return ttl
+				}
+				return noCacheTTL
+			}
+		}
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		// a invalid Expires (ex: 0) means already expired (RFC 9111)
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return noCacheTTL
+		}
+		if ttl := time.Until(expiresAt); ttl > 0 {
+			return ttl
+		}
+		return noCacheTTL
+	}
+
+	return 0
+}
@@ -0,0 +1,142 @@
+package jwks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"context"
+	"testing"
+	"net/http"
+	"sync/atomic"
+	"net/http/httptest"
+
+	"github.com/rs/zerolog"
+)
+
+// newTestIssuer is a OIDC issuer stand-in, the jwks is served with the cache control informed
+func newTestIssuer(t *testing.T, issuer string, cacheControl string, discoveries *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			discoveries.Add(1)
+			discoveryIssuer := issuer
+			if discoveryIssuer == "" {
+				discoveryIssuer = server.URL
+			}
+			fmt.Fprintf(w, `{"issuer":"%s","jwks_uri":"%s/jwks"}`, discoveryIssuer, server.URL)
+		case "/jwks":
+			if cacheControl != "" {
+				w.Header().Set("Cache-Control", cacheControl)
+			}
+			fmt.Fprint(w, `{"keys":[{"kty":"RSA","kid":"k1","use":"sig","n":"AQAB","e":"AQAB"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestOidcJwksFetcherFetchJwks(t *testing.T) {
+	tests := []struct {
+		name			string
+		issuer			string
+		cacheControl	string
+		wantTTL			time.Duration
+		wantErr			bool
+	}{
+		{name: "max-age", cacheControl: "public, max-age=600", wantTTL: 600 * time.Second},
+		{name: "no cache headers", wantTTL: 0},
+		{name: "no-store", cacheControl: "no-store", wantTTL: noCacheTTL},
+		{name: "no-cache", cacheControl: "no-cache, max-age=600", wantTTL: noCacheTTL},
+		{name: "issuer mismatch", issuer: "https://other.localhost", wantErr: true},
+	}
+
+	logger := zerolog.Nop()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discoveries := &atomic.Int32{}
+			server := newTestIssuer(t, tt.issuer, tt.cacheControl, discoveries)
+
+			fetcher := NewOidcJwksFetcher(server.URL + "/", nil, &logger)
+			jwks, ttl, err := fetcher.FetchJwks(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("FetchJwks() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchJwks() error = %v", err)
+			}
+			if len(jwks.JwtKeyInfo) != 1 || jwks.JwtKeyInfo[0].Kid != "k1" {
+				t.Fatalf("FetchJwks() keys = %+v, want kid k1", jwks.JwtKeyInfo)
+			}
+			if ttl != tt.wantTTL {
+				t.Fatalf("FetchJwks() ttl = %v, want %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestOidcJwksFetcherDiscoverOnce(t *testing.T) {
+	logger := zerolog.Nop()
+	discoveries := &atomic.Int32{}
+	server := newTestIssuer(t, "", "max-age=60", discoveries)
+
+	fetcher := NewOidcJwksFetcher(server.URL, nil, &logger)
+
+	// cache refresh and unknown kid fetches at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := fetcher.FetchJwks(context.Background()); err != nil {
+				t.Errorf("FetchJwks() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := discoveries.Load(); got != 1 {
+		t.Fatalf("discoveries = %d, want 1", got)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	tests := []struct {
+		name	string
+		header	http.Header
+		want	time.Duration
+	}{
+		{name: "not informed", header: http.Header{}, want: 0},
+		{name: "max-age", header: http.Header{"Cache-Control": {"max-age=300"}}, want: 300 * time.Second},
+		{name: "max-age minus age", header: http.Header{"Cache-Control": {"max-age=300"}, "Age": {"100"}}, want: 200 * time.Second},
+		{name: "max-age already expired", header: http.Header{"Cache-Control": {"max-age=300"}, "Age": {"400"}}, want: noCacheTTL},
+		{name: "max-age zero", header: http.Header{"Cache-Control": {"max-age=0"}}, want: noCacheTTL},
+		{name: "no-store", header: http.Header{"Cache-Control": {"no-store"}}, want: noCacheTTL},
+		{name: "no-cache case insensitive", header: http.Header{"Cache-Control": {"public, No-Cache"}}, want: noCacheTTL},
+		{name: "expires in the past", header: http.Header{"Expires": {"Thu, 01 Jan 1970 00:00:00 GMT"}}, want: noCacheTTL},
+		{name: "expires invalid", header: http.Header{"Expires": {"0"}}, want: noCacheTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheTTL(tt.header); got != tt.want {
+				t.Fatalf("cacheTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheTTLExpiresInTheFuture(t *testing.T) {
+	header := http.Header{"Expires": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}
+	if got := cacheTTL(header); got < 59 * time.Minute || got > time.Hour {
+		t.Fatalf("cacheTTL() = %v, want about 1h", got)
+	}
+}
@@ -0,0 +1,71 @@
+package ocsp
+
+import(
+	"io"
+	"fmt"
+	"time"
+	"bytes"
+	"context"
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+)
+
+// OcspClient sends the ocsp request to the responder informed in the certificate AIA (RFC 6960)
+type OcspClient struct {
+	httpClient	*http.Client
+	logger		*zerolog.Logger
+}
+
+// About create a ocsp client
+func NewOcspClient(timeout time.Duration,
+				   appLogger *zerolog.Logger) *OcspClient {
+
+	logger := appLogger.With().
+					Str("package", "infrastructure.ocsp").
+					Logger()
+
+	logger.Info().
+		Str("func","NewOcspClient").Send()
+
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+
+	return &OcspClient{
+		httpClient: &http.Client{Timeout: timeout},
+		logger: &logger,
+	}
+}
+
+// About post the der ocsp request to the responder and return the der ocsp response
+func (o *OcspClient) Query(ctx context.Context, responderUrl string, ocspRequest []byte) ([]byte, error) {
+	o.logger.Debug().
+		Ctx(ctx).
+		Str("func","Query").
+		Str("responder_url", responderUrl).Send()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responderUrl, bytes.NewReader(ocspRequest))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		o.logger.Error().
+			Ctx(ctx).
+			Err(err).Send()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: ocsp responder http status %d", erro.ErrServer, resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1 << 20))
+}
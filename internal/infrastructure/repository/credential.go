@@ -0,0 +1,62 @@
+package repository
+
+import(
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+
+	"github.com/lambda-go-oauth2/internal/domain/model"
+
+	go_core_aws_dynamo "github.com/eliezerraj/go-core/v2/aws/dynamoDB"
+)
+
+// CredentialScopeRepository reads the user scopes (roles) from the dynamo table
+// Item: ID = USER-<user>, SK = SCOPE-001, scope = list of scopes
+type CredentialScopeRepository struct {
+	database	*go_core_aws_dynamo.DatabaseDynamoDB
+	tableName	string
+	logger		*zerolog.Logger
+}
+
+// About create a credential scope repository
+func NewCredentialScopeRepository(database *go_core_aws_dynamo.DatabaseDynamoDB,
+								  tableName string,
+								  appLogger *zerolog.Logger) *CredentialScopeRepository {
+
+	logger := appLogger.With().
+					Str("package", "infrastructure.repository").
+					Logger()
+
+	logger.Info().
+		Str("func","NewCredentialScopeRepository").Send()
+
+	return &CredentialScopeRepository{
+		database: database,
+		tableName: tableName,
+		logger: &logger,
+	}
+}
+
+// About get the user credential scope, nil when the user has no scope item
+func (r *CredentialScopeRepository) GetCredentialScope(ctx context.Context, user string) (*model.CredentialScope, error) {
+	r.logger.Debug().
+		Ctx(ctx).
+		Str("func","GetCredentialScope").Send()
+
+	items, err := r.database.QueryInput(ctx, &r.tableName, "USER-" + user, "SCOPE-001")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	credentialScope := model.CredentialScope{}
+	if err := attributevalue.UnmarshalMap(items[0], &credentialScope); err != nil {
+		return nil, err
+	}
+
+	return &credentialScope, nil
+}
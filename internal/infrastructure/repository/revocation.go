@@ -0,0 +1,66 @@
+package repository
+
+import(
+	"time"
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+
+	"github.com/lambda-go-oauth2/internal/domain/model"
+
+	go_core_aws_dynamo "github.com/eliezerraj/go-core/v2/aws/dynamoDB"
+)
+
+// RevocationRepository reads the revoked token id (jti or jwt_id) from the dynamo table
+// Item: ID = JWT_ID-<id>, SK = REVOKED, TimeToLive = token exp (epoch)
+type RevocationRepository struct {
+	database	*go_core_aws_dynamo.DatabaseDynamoDB
+	tableName	string
+	logger		*zerolog.Logger
+}
+
+// About create a revocation repository
+func NewRevocationRepository(database *go_core_aws_dynamo.DatabaseDynamoDB,
+							 tableName string,
+							 appLogger *zerolog.Logger) *RevocationRepository {
+
+	logger := appLogger.With().
+					Str("package", "infrastructure.repository").
+					Logger()
+
+	logger.Info().
+		Str("func","NewRevocationRepository").Send()
+
+	return &RevocationRepository{
+		database: database,
+		tableName: tableName,
+		logger: &logger,
+	}
+}
+
+// About check if the token id is in the denylist
+// The dynamo TTL delete is not immediate, so an expired item is ignored
+func (r *RevocationRepository) IsRevoked(ctx context.Context, tokenId string) (bool, error) {
+	r.logger.Debug().
+		Ctx(ctx).
+		Str("func","IsRevoked").Send()
+
+	items, err := r.database.QueryInput(ctx, &r.tableName, "JWT_ID-" + tokenId, "REVOKED")
+	if err != nil {
+		return false, err
+	}
+
+	for _, item := range items {
+		tokenRevoked := model.TokenRevoked{}
+		if err := attributevalue.UnmarshalMap(item, &tokenRevoked); err != nil {
+			return false, err
+		}
+
+		if tokenRevoked.TimeToLive == 0 || time.Unix(tokenRevoked.TimeToLive, 0).After(time.Now()) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
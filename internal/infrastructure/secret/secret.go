@@ -0,0 +1,101 @@
+package secret
+
+import(
+	"os"
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+)
+
+// SecretsManagerProvider gets the secret value from AWS Secrets Manager
+type SecretsManagerProvider struct {
+	client		*secretsmanager.Client
+	secretName	string
+	logger		*zerolog.Logger
+}
+
+// About create a secrets manager provider
+func NewSecretsManagerProvider(awsConfig *aws.Config,
+							   secretName string,
+							   appLogger *zerolog.Logger) *SecretsManagerProvider {
+
+	logger := appLogger.With().
+					Str("package", "infrastructure.secret").
+					Logger()
+
+	logger.Info().
+		Str("func","NewSecretsManagerProvider").Send()
+
+	return &SecretsManagerProvider{
+		client: secretsmanager.NewFromConfig(*awsConfig),
+		secretName: secretName,
+		logger: &logger,
+	}
+}
+
+// About get the current version (AWSCURRENT) of the secret
+func (s *SecretsManagerProvider) GetSecret(ctx context.Context) (string, error) {
+	s.logger.Debug().
+		Ctx(ctx).
+		Str("func","GetSecret").Send()
+
+	output, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.secretName),
+	})
+	if err != nil {
+		s.logger.Error().
+			Ctx(ctx).
+			Err(err).Send()
+		return "", err
+	}
+
+	if output.SecretString == nil {
+		return "", erro.ErrNotFound
+	}
+
+	return *output.SecretString, nil
+}
+
+// FileSecretProvider gets the secret value from a local file (local runs)
+type FileSecretProvider struct {
+	filePath	string
+	logger		*zerolog.Logger
+}
+
+// About create a file provider
+func NewFileSecretProvider(filePath string,
+						   appLogger *zerolog.Logger) *FileSecretProvider {
+
+	logger := appLogger.With().
+					Str("package", "infrastructure.secret").
+					Logger()
+
+	logger.Info().
+		Str("func","NewFileSecretProvider").Send()
+
+	return &FileSecretProvider{
+		filePath: filePath,
+		logger: &logger,
+	}
+}
+
+// About read the secret file
+func (f *FileSecretProvider) GetSecret(ctx context.Context) (string, error) {
+	f.logger.Debug().
+		Ctx(ctx).
+		Str("func","GetSecret").Send()
+
+	secret, err := os.ReadFile(f.filePath)
+	if err != nil {
+		f.logger.Error().
+			Ctx(ctx).
+			Err(err).Send()
+		return "", err
+	}
+
+	return string(secret), nil
+}
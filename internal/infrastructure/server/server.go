@@ -1,19 +1,23 @@
 package server
 
 import(
+	"errors"
 	"context"
 	"strings"
+	"crypto/x509"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/aws/aws-lambda-go/events"
 
 	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/shared/certificate"
 	"github.com/lambda-go-oauth2/internal/domain/model"
 	"github.com/lambda-go-oauth2/internal/domain/service"
 	
 	"go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/attribute"
 
 	go_core_otel_trace "github.com/eliezerraj/go-core/v2/otel/trace"
 	go_core_middleware "github.com/eliezerraj/go-core/v2/middleware" // used to get request ID from context
@@ -22,6 +26,48 @@ import(
 var response *events.APIGatewayProxyResponse
 var policyData model.PolicyData
 
+// deny reason code and message of each validation error
+type denyReason struct {
+	code	string
+	message	string
+}
+
+var denyReasons = map[error]denyReason{
+	erro.ErrArnMalFormad:			{"arn_invalid", "token validation - arn invalid"},
+	erro.ErrBearTokenFormad:		{"bearer_token_invalid", "token validation - beared token invalid"},
+	erro.ErrTokenMalformed:			{"token_malformed", "token validation - token malformed"},
+	erro.ErrTokenUnverifiable:		{"token_unverifiable", "token validation - token unverifiable"},
+	erro.ErrSignatureInvalid:		{"signature_invalid", "token validation - signature invalid"},
+	erro.ErrAlgorithmNotAllowed:	{"algorithm_not_allowed", "token validation - algorithm not allowed"},
+	erro.ErrKidNotFound:			{"kid_not_found", "token validation - kid not found"},
+	erro.ErrTokenExpired:			{"token_expired", "token validation - token expired"},
+	erro.ErrTokenNotValidYet:		{"token_not_valid_yet", "token validation - token not valid yet"},
+	erro.ErrTokenUsedBeforeIssued:	{"token_used_before_issued", "token validation - token used before issued"},
+	erro.ErrTokenTooOld:			{"token_too_old", "token validation - token max age exceeded"},
+	erro.ErrTokenLifetime:			{"token_lifetime_exceeded", "token validation - token max lifetime exceeded"},
+	erro.ErrTokenClaimMissing:		{"claim_missing", "token validation - required claim missing"},
+	erro.ErrTokenInvalidClaims:		{"claims_invalid", "token validation - claims invalid"},
+	erro.ErrTokenIssuer:			{"issuer_not_allowed", "token validation - issuer not allowed"},
+	erro.ErrTokenAudience:			{"audience_not_allowed", "token validation - audience not allowed"},
+	erro.ErrTokenUse:				{"token_use_not_allowed", "token validation - token_use not allowed"},
+	erro.ErrTokenRevoked:			{"token_revoked", "token validation - token revoked"},
+	erro.ErrRevocationCheck:		{"revocation_check_failed", "token validation - revocation check failed"},
+	erro.ErrCredentialScope:		{"user_scope_check_failed", "token validation - user scopes load failed"},
+	erro.ErrTokenInactive:			{"token_inactive", "token validation - token not active"},
+	erro.ErrIntrospection:			{"introspection_failed", "token validation - introspection failed"},
+	erro.ErrTokenDecrypt:			{"token_decryption_failed", "token validation - token decryption failed"},
+	erro.ErrTokenNotEncrypted:		{"token_not_encrypted", "token validation - token encryption required"},
+	erro.ErrCertBinding:			{"cert_binding_mismatch", "token validation - token not bound to the client certificate"},
+	erro.ErrCertRevoked:			{"cert_revoked", "client certificate validation - certificate revoked"},
+	erro.ErrCrlCheck:				{"crl_check_failed", "client certificate validation - crl not valid"},
+	erro.ErrOcspCheck:				{"ocsp_check_failed", "client certificate validation - ocsp status not known"},
+	erro.ErrCertChain:				{"cert_chain_invalid", "client certificate validation - certificate chain not valid"},
+	erro.ErrSpiffeId:				{"spiffe_id_invalid", "client certificate validation - spiffe id not valid"},
+	erro.ErrParseCert:				{"cert_invalid", "client certificate validation - certificate invalid"},
+	erro.ErrScopeNotAllowed:		{"scope_not_allowed", "unauthorized by token validation"},
+	erro.ErrStatusUnauthorized:		{"unauthorized", "unauthorized"},
+}
+
 type Server struct {
 	appServer 		*model.AppServer
 	workerService 	*service.WorkerService	
@@ -73,53 +119,151 @@ func (s *Server) LambdaHandlerRequest(ctx context.Context,
 	policyData.Effect = "Deny"
 	policyData.PrincipalID = "go-oauth-apigw-authorization-lambda"
 	policyData.Message = "unauthorized"
+	policyData.Reason = ""
+	policyData.ClientCert = nil
 	policyData.MethodArn = request.MethodArn
 
-	//token structure
+	//token structure, without token the SPIFFE ID of the client certificate can be the principal
 	bearerToken, err := s.tokenStructureValidation(ctx, request)
-	if err != nil{
-		switch err {
-		case erro.ErrArnMalFormad:
-			policyData.Message = "token validation - arn invalid"
-		case erro.ErrBearTokenFormad:
-			policyData.Message = "token validation - beared token invalid"
-		default:
-			policyData.Message = "token validation"
+	spiffeOnly := errors.Is(err, erro.ErrBearTokenFormad) && s.workerService.SpiffeEnabled()
+	if err != nil && !spiffeOnly {
+		return s.denyPolicy(ctx, err, nil), nil
+	}
+
+	// The mTLS client certificate is parsed once for all the checks, nil when not informed
+	var certX509 *x509.Certificate
+	if clientCertPem := request.RequestContext.Identity.ClientCert.ClientCertPem; clientCertPem != "" {
+		certX509, err = certificate.ParsePemToCertx509(&clientCertPem, s.logger)
+		if err != nil {
+			return s.denyPolicy(ctx, erro.ErrParseCert, nil), nil
 		}
-		return s.workerService.GeneratePolicyFromClaims(ctx, policyData, nil), nil
 	}
 
-	var KeySign	interface{}
-	if s.appServer.Application.AuthenticationModel == "RSA" {
-		KeySign = s.appServer.RsaKey.RsaPublic
-	} else {
-		KeySign = s.appServer.RsaKey.HsaKey
+	// Check the mTLS client certificate chain, the subject and SANs are informed to the backends
+	clientCert, err := s.workerService.ClientCertChainValidation(ctx, certX509)
+	if err != nil {
+		return s.denyPolicy(ctx, err, nil), nil
+	}
+	policyData.ClientCert = clientCert
+
+	// Check the mTLS client certificate revocation (crl)
+	if err := s.workerService.ClientCertRevocationValidation(ctx, certX509); err != nil {
+		return s.denyPolicy(ctx, err, nil), nil
+	}
+
+	// Check the mTLS client certificate status in the ocsp responder
+	if err := s.workerService.ClientCertOcspValidation(ctx, certX509); err != nil {
+		return s.denyPolicy(ctx, err, nil), nil
 	}
 
-	// Check token signature
-	claims, err := s.workerService.TokenSignedValidation(*bearerToken, KeySign, s.logger)
+	var claims *model.JwtData
+	if spiffeOnly {
+		claims, err = s.workerService.SpiffePrincipal(ctx, certX509)
+	} else {
+		claims, err = s.tokenValidation(ctx, *bearerToken, certX509)
+	}
 	if err != nil {
-		s.logger.Error().
-				 Err(err).
-	             Msg("erro TokenSignedValidation")
-		return s.workerService.GeneratePolicyFromClaims(ctx, policyData, claims), nil
+		return s.denyPolicy(ctx, err, claims), nil
 	}
 
 	// Scope ON
 	if (true) {
 		// Check scope
-		if !s.workerService.ScopeValidation(ctx, *claims, policyData.MethodArn) {
-			policyData.Message = "unauthorized by token validation"
-			return s.workerService.GeneratePolicyFromClaims(ctx, policyData, claims), nil
+		requestData := &model.RequestData{
+			Headers: request.Headers,
+			QueryParameters: request.QueryStringParameters,
+			SourceIp: request.RequestContext.Identity.SourceIP,
+		}
+		if !s.workerService.ScopeValidation(ctx, *claims, policyData.MethodArn, requestData) {
+			return s.denyPolicy(ctx, erro.ErrScopeNotAllowed, claims), nil
 		} 
 	}
 
 	policyData.Effect = "Allow"
 	policyData.Message = "Authorized"
+	policyData.Reason = "authorized"
 
 	return s.workerService.GeneratePolicyFromClaims(ctx, policyData, claims), nil	
 }
 
+// About check the token (jwe, jws or opaque), its claims, the certificate binding, the revocation and the user scopes
+func (s *Server) tokenValidation(ctx context.Context,
+								 bearerToken string,
+								 certX509 *x509.Certificate) (*model.JwtData, error) {
+
+	// Decrypt the encrypted token (nested jwe), the inner jws is verified below
+	bearerToken, err := s.workerService.TokenDecryption(ctx, bearerToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check token signature (or introspect the opaque token)
+	opaqueToken := s.workerService.IsOpaqueToken(bearerToken)
+
+	var claims *model.JwtData
+	if opaqueToken {
+		claims, err = s.workerService.TokenIntrospectionValidation(ctx, bearerToken)
+	} else {
+		claims, err = s.workerService.TokenSignedValidation(ctx, bearerToken)
+	}
+	if err != nil {
+		return claims, err
+	}
+
+	// Check issuer, audience and token_use
+	if err := s.workerService.ClaimsValidation(ctx, *claims, policyData.MethodArn); err != nil {
+		return claims, err
+	}
+
+	// Check the certificate-bound token (cnf x5t#S256 of the mTLS client certificate)
+	if err := s.workerService.CertificateBindingValidation(ctx, *claims, certX509); err != nil {
+		return claims, err
+	}
+
+	// Check token revocation (jwt_id denylist), a opaque token revoked is not active in the introspection
+	if !opaqueToken {
+		if err := s.workerService.RevocationValidation(ctx, *claims); err != nil {
+			return claims, err
+		}
+	}
+
+	// Keep only the token scopes also granted by the current user scopes (rbac)
+	if err := s.workerService.CredentialScopeValidation(ctx, claims); err != nil {
+		return claims, err
+	}
+
+	return claims, nil
+}
+
+// About deny the request, the reason code and message are choosen by the error
+// The reason is recorded in the lambda span and returned in the authorizer context
+func (s *Server) denyPolicy(ctx context.Context,
+							err error,
+							claims *model.JwtData) events.APIGatewayCustomAuthorizerResponse {
+
+	reason, ok := denyReasons[err]
+	if !ok {
+		reason = denyReasons[erro.ErrStatusUnauthorized]
+	}
+
+	policyData.Effect = "Deny"
+	policyData.Reason = reason.code
+	policyData.Message = reason.message
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("auth.reason", reason.code))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, reason.code)
+
+	s.logger.Error().
+			 Ctx(ctx).
+			 Err(err).
+			 Str("reason", reason.code).
+			 Msg(reason.message)
+
+	return s.workerService.GeneratePolicyFromClaims(ctx, policyData, claims)
+}
+
 // About check the token structure
 func (s *Server) tokenStructureValidation(ctx context.Context, 
 										  request events.APIGatewayCustomAuthorizerRequestTypeRequest) (*string, error){
@@ -0,0 +1,180 @@
+package source
+
+import(
+	"os"
+	"io"
+	"fmt"
+	"bytes"
+	"time"
+	"context"
+	"strings"
+	"net/http"
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+	"github.com/rs/zerolog"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+
+	go_core_aws_s3 "github.com/eliezerraj/go-core/v2/aws/s3"
+)
+
+// SourceLoader loads a document from a file, a S3 object or a HTTP url
+type SourceLoader struct {
+	bucketS3	*go_core_aws_s3.AwsBucketS3
+	httpClient	*http.Client
+	logger		*zerolog.Logger
+}
+
+// About create a source loader
+func NewSourceLoader(bucketS3 *go_core_aws_s3.AwsBucketS3,
+					 appLogger *zerolog.Logger) *SourceLoader {
+
+	logger := appLogger.With().
+					Str("package", "infrastructure.source").
+					Logger()
+
+	logger.Info().
+		Str("func","NewSourceLoader").Send()
+
+	return &SourceLoader{
+		bucketS3: bucketS3,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		logger: &logger,
+	}
+}
+
+// About load the document
+// source formats: s3://bucket/key, http(s)://host/path, file:///path or a local path
+func (l *SourceLoader) Load(ctx context.Context, source string) ([]byte, error) {
+	l.logger.Info().
+		Ctx(ctx).
+		Str("func","Load").
+		Str("source", source).Send()
+
+	var raw []byte
+	var err error
+
+	switch {
+	case strings.HasPrefix(source, "s3://"):
+		raw, err = l.loadS3(ctx, strings.TrimPrefix(source, "s3://"))
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		raw, err = l.loadHttp(ctx, source)
+	default:
+		raw, err = os.ReadFile(strings.TrimPrefix(source, "file://"))
+	}
+	if err != nil {
+		l.logger.Error().
+			Ctx(ctx).
+			Err(err).Send()
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// About get the document from a S3 object (bucket/key)
+func (l *SourceLoader) loadS3(ctx context.Context, location string) ([]byte, error) {
+	if l.bucketS3 == nil {
+		return nil, fmt.Errorf("%w: s3 client not informed", erro.ErrBadRequest)
+	}
+
+	bucketKey := strings.SplitN(location, "/", 2)
+	if len(bucketKey) != 2 || bucketKey[0] == "" || bucketKey[1] == "" {
+		return nil, fmt.Errorf("%w: invalid s3 location %s", erro.ErrBadRequest, location)
+	}
+
+	object, err := l.bucketS3.GetObject(ctx, bucketKey[0], "", bucketKey[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(*object), nil
+}
+
+// About get the document from a http url
+func (l *SourceLoader) loadHttp(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s http status %d", erro.ErrServer, url, resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// S3ObjectSource is a object of the keys bucket loaded on demand (ex: the crl reloaded on a schedule)
+type S3ObjectSource struct {
+	bucketS3	*go_core_aws_s3.AwsBucketS3
+	bucketName	string
+	filePath	string
+	fileKey		string
+}
+
+// About create a s3 object source
+func NewS3ObjectSource(bucketS3 *go_core_aws_s3.AwsBucketS3,
+					   bucketName string,
+					   filePath string,
+					   fileKey string) *S3ObjectSource {
+	return &S3ObjectSource{
+		bucketS3: bucketS3,
+		bucketName: bucketName,
+		filePath: filePath,
+		fileKey: fileKey,
+	}
+}
+
+// About get the object
+func (o *S3ObjectSource) Load(ctx context.Context) ([]byte, error) {
+	object, err := o.bucketS3.GetObject(ctx, o.bucketName, o.filePath, o.fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(*object), nil
+}
+
+// About decode a JSON or YAML document in v (json tags)
+// The YAML is converted to JSON, so the models keep just the json tags and both are decoded by the same strict path
+// A unknown field (ex: a typo as conditon) is rejected instead of silently ignored
+func Decode(raw []byte, v interface{}) error {
+	rawJson := bytes.TrimSpace(raw)
+	if len(rawJson) == 0 || (rawJson[0] != '{' && rawJson[0] != '[') {
+		var document interface{}
+		if err := yaml.Unmarshal(rawJson, &document); err != nil {
+			return fmt.Errorf("%w: %v", erro.ErrUnmarshal, err)
+		}
+
+		var err error
+		if rawJson, err = json.Marshal(document); err != nil {
+			return fmt.Errorf("%w: %v", erro.ErrUnmarshal, err)
+		}
+	}
+
+	return DecodeJson(rawJson, v)
+}
+
+// About decode a JSON document in v rejecting the unknown fields
+func DecodeJson(rawJson []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(rawJson))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", erro.ErrUnmarshal, err)
+	}
+	// only one document is accepted
+	if decoder.More() {
+		return fmt.Errorf("%w: unexpected data after the document", erro.ErrUnmarshal)
+	}
+
+	return nil
+}
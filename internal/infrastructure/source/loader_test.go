@@ -0,0 +1,61 @@
+package source
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lambda-go-oauth2/shared/erro"
+	"github.com/lambda-go-oauth2/internal/domain/model"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name		string
+		raw			string
+		wantErr		error
+	}{
+		{name: "json", raw: `{"superscopes":["admin"],"routes":[{"path":"reports/**","methods":["GET"],"scopes":["reports:read"],"condition":"claims.tier == \"tier1\""}]}`},
+		{name: "yaml", raw: "superscopes: [admin]\nroutes:\n  - path: reports/**\n    methods: [GET]\n    scopes: [reports:read]\n    condition: claims.tier == \"tier1\"\n"},
+		{name: "json unknown field", raw: `{"routes":[{"path":"reports/**","methods":["GET"],"scopes":["reports:read"],"conditon":"claims.tier == \"tier1\""}]}`, wantErr: erro.ErrUnmarshal},
+		{name: "yaml typo conditon", raw: "routes:\n  - path: reports/**\n    methods: [GET]\n    scopes: [reports:read]\n    conditon: claims.tier == \"tier1\"\n", wantErr: erro.ErrUnmarshal},
+		{name: "json trailing document", raw: `{"superscopes":["admin"]} {"superscopes":["root"]}`, wantErr: erro.ErrUnmarshal},
+		{name: "yaml invalid", raw: "routes: [", wantErr: erro.ErrUnmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scopePolicy := model.ScopePolicy{}
+			err := Decode([]byte(tt.raw), &scopePolicy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(scopePolicy.Routes) != 1 || scopePolicy.Routes[0].Condition != `claims.tier == "tier1"` {
+				t.Fatalf("Decode() routes = %+v, want the route with the condition", scopePolicy.Routes)
+			}
+		})
+	}
+}
+
+func TestDecodeJsonTrustConfig(t *testing.T) {
+	tests := []struct {
+		name		string
+		raw			string
+		wantErr		error
+	}{
+		{name: "valid", raw: `{"issuers":[{"issuer":"https://issuer.example.com","key_source":"oidc","allowed_algorithms":["RS256"],"claim_mapping":{"username":"preferred_username"}}]}`},
+		{name: "typo audience", raw: `{"issuers":[{"issuer":"https://issuer.example.com","key_source":"oidc","allowed_algorithms":["RS256"],"audience":["api"]}]}`, wantErr: erro.ErrUnmarshal},
+		{name: "typo claim mapping", raw: `{"issuers":[{"issuer":"https://issuer.example.com","key_source":"oidc","allowed_algorithms":["RS256"],"claim_maping":{"username":"preferred_username"}}]}`, wantErr: erro.ErrUnmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trustConfig := model.TrustConfig{}
+			if err := DecodeJson([]byte(tt.raw), &trustConfig); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeJson() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
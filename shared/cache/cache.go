@@ -0,0 +1,100 @@
+//---------------------------------------
+// Component is charge of a small in-memory cache with ttl
+//---------------------------------------
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type item[V any] struct {
+	value		V
+	expiresAt	time.Time
+}
+
+// Cache is a in-memory cache with ttl per item and a max number of items
+type Cache[V any] struct {
+	mutex		sync.Mutex
+	items		map[string]item[V]
+	maxItems	int
+}
+
+// About create a cache, when full the expired items are removed and, if still full, the older one
+func NewCache[V any](maxItems int) *Cache[V] {
+	if maxItems <= 0 {
+		maxItems = 1000
+	}
+	return &Cache[V]{
+		items: make(map[string]item[V]),
+		maxItems: maxItems,
+	}
+}
+
+// About get a item not expired
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	it, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if time.Now().After(it.expiresAt) {
+		delete(c.items, key)
+		var zero V
+		return zero, false
+	}
+
+	return it.value, true
+}
+
+// About set a item with ttl
+func (c *Cache[V]) Set(key string, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.items[key]; !ok && len(c.items) >= c.maxItems {
+		c.evict()
+	}
+
+	c.items[key] = item[V]{
+		value: value,
+		expiresAt: time.Now().Add(ttl),
+	}
+}
+
+// About remove a item
+func (c *Cache[V]) Delete(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.items, key)
+}
+
+// About remove the expired items, if none remove the item closest to expire
+func (c *Cache[V]) evict() {
+	now := time.Now()
+
+	var oldestKey string
+	var oldestExpiresAt time.Time
+	for key, it := range c.items {
+		if now.After(it.expiresAt) {
+			delete(c.items, key)
+			continue
+		}
+		if oldestKey == "" || it.expiresAt.Before(oldestExpiresAt) {
+			oldestKey = key
+			oldestExpiresAt = it.expiresAt
+		}
+	}
+
+	if len(c.items) >= c.maxItems && oldestKey != "" {
+		delete(c.items, oldestKey)
+	}
+}
@@ -2,6 +2,8 @@ package certificate
 
 import (
 	"crypto/rsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
     "encoding/pem"
 	"crypto/x509"
 	"errors"
@@ -80,4 +82,122 @@ func ParsePemToCertx509(certX509pem *string,
     }
 
 	return certX509, nil
-}
\ No newline at end of file
+}
+
+// About convert a key pem string in ecdsa public key (P-256, P-384, P-521)
+func ParsePemToECDSAPub(public_key *string,
+						logger *zerolog.Logger) (*ecdsa.PublicKey, error){
+	logger.Info().
+			Str("func","ParsePemToECDSAPub").Send()
+
+	pubInterface, err := parsePemToPKIXPub(public_key)
+	if err != nil {
+		logger.Error().
+			   Err(err).Send()
+		return nil, err
+	}
+
+	key_ecdsa, ok := pubInterface.(*ecdsa.PublicKey)
+	if !ok {
+		logger.Error().
+			   Err(errors.New("erro PUBLIC KEY is not ECDSA")).Send()
+		return nil, errors.New("erro PUBLIC KEY is not ECDSA")
+	}
+
+	return key_ecdsa, nil
+}
+
+// About convert a key pem string in ed25519 public key
+func ParsePemToEd25519Pub(public_key *string,
+						  logger *zerolog.Logger) (ed25519.PublicKey, error){
+	logger.Info().
+			Str("func","ParsePemToEd25519Pub").Send()
+
+	pubInterface, err := parsePemToPKIXPub(public_key)
+	if err != nil {
+		logger.Error().
+			   Err(err).Send()
+		return nil, err
+	}
+
+	key_ed25519, ok := pubInterface.(ed25519.PublicKey)
+	if !ok {
+		logger.Error().
+			   Err(errors.New("erro PUBLIC KEY is not Ed25519")).Send()
+		return nil, errors.New("erro PUBLIC KEY is not Ed25519")
+	}
+
+	return key_ed25519, nil
+}
+
+// About decode a PUBLIC KEY pem block and parse it as PKIX
+func parsePemToPKIXPub(public_key *string) (interface{}, error){
+	block, _ := pem.Decode([]byte(*public_key))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("erro PUBLIC KEY Decode")
+	}
+
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// About convert a pem bundle (one or more certificates) in cert x509 list
+func ParsePemToCertx509List(certX509pem *string,
+							logger *zerolog.Logger) ([]*x509.Certificate, error) {
+	logger.Info().
+			Str("func","ParsePemToCertx509List").Send()
+
+	certsX509 := []*x509.Certificate{}
+	rest := []byte(*certX509pem)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		certX509, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			logger.Error().
+				   Err(err).Send()
+			return nil, err
+		}
+		certsX509 = append(certsX509, certX509)
+	}
+
+	if len(certsX509) == 0 {
+		logger.Error().
+			   Err(errors.New("erro CERT X509 Decode")).Send()
+		return nil, errors.New("erro CERT X509 Decode")
+	}
+
+	return certsX509, nil
+}
+
+// About convert a crl (pem X509 CRL or der) in revocation list
+func ParsePemToCrl(crl *string,
+				   logger *zerolog.Logger) (*x509.RevocationList, error) {
+	logger.Info().
+			Str("func","ParsePemToCrl").Send()
+
+	crlDer := []byte(*crl)
+	if block, _ := pem.Decode(crlDer); block != nil {
+		if block.Type != "X509 CRL" {
+			logger.Error().
+				   Err(errors.New("erro X509 CRL Decode")).Send()
+			return nil, errors.New("erro X509 CRL Decode")
+		}
+		crlDer = block.Bytes
+	}
+
+	revocationList, err := x509.ParseRevocationList(crlDer)
+	if err != nil {
+		logger.Error().
+			   Err(err).Send()
+		return nil, err
+	}
+
+	return revocationList, nil
+}
@@ -32,4 +32,30 @@ var (
 	ErrQueryEmpty	= errors.New("query parameters missing")
 	ErrCertRevoked	= errors.New("error cert revoke")
 	ErrCredentials	= errors.New("credential informed is invalid (user or password) ")
+	ErrKidNotFound	= errors.New("kid not found in key set")
+	ErrAlgorithmNotAllowed	= errors.New("token signing algorithm not allowed")
+	ErrTokenIssuer	= errors.New("token issuer not allowed")
+	ErrTokenAudience	= errors.New("token audience not allowed")
+	ErrTokenUse		= errors.New("token_use not allowed")
+	ErrTokenMalformed	= errors.New("token malformed")
+	ErrTokenUnverifiable	= errors.New("token unverifiable")
+	ErrTokenNotValidYet	= errors.New("token not valid yet")
+	ErrTokenUsedBeforeIssued	= errors.New("token used before issued")
+	ErrTokenClaimMissing	= errors.New("token required claim missing")
+	ErrTokenInvalidClaims	= errors.New("token claims invalid")
+	ErrTokenTooOld	= errors.New("token exceeded the max age")
+	ErrTokenLifetime	= errors.New("token exceeded the max lifetime")
+	ErrScopeNotAllowed	= errors.New("scope not allowed")
+	ErrTokenRevoked	= errors.New("token revoked")
+	ErrRevocationCheck	= errors.New("token revocation check failed")
+	ErrTokenInactive	= errors.New("token not active")
+	ErrIntrospection	= errors.New("token introspection failed")
+	ErrTokenDecrypt		= errors.New("token decryption failed")
+	ErrTokenNotEncrypted	= errors.New("token encryption required")
+	ErrCertBinding	= errors.New("token not bound to the client certificate")
+	ErrCrlCheck		= errors.New("certificate revocation list not valid")
+	ErrOcspCheck	= errors.New("certificate ocsp status not known")
+	ErrCertChain	= errors.New("client certificate chain not valid")
+	ErrSpiffeId		= errors.New("client certificate spiffe id not valid")
+	ErrCredentialScope	= errors.New("user scopes load failed")
 )